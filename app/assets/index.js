@@ -1 +1,18 @@
-console.log("if you like superkit consider given it a star on GitHub.")
\ No newline at end of file
+console.log("if you like superkit consider given it a star on GitHub.")
+
+// Send the CSRF token with every htmx request.
+document.addEventListener("htmx:configRequest", (event) => {
+  const meta = document.querySelector('meta[name="csrf-token"]')
+  if (meta) {
+    event.detail.headers["X-CSRF-Token"] = meta.content
+  }
+})
+
+// htmx doesn't swap error responses. Show the error pages, like the one
+// for an expired CSRF token, instead of silently ignoring the request.
+document.addEventListener("htmx:beforeSwap", (event) => {
+  if (event.detail.xhr.status >= 400) {
+    event.detail.shouldSwap = true
+    event.detail.target = document.body
+  }
+})
@@ -1,3 +1,422 @@
+// Package conf holds the typed configuration of the application.
+//
+// Every setting has an environment variable. The variables can also be set
+// in an optional JSON file, an object keyed by the variable names, e.g.
+//
+//	{"HTTP_LISTEN_ADDR": ":8080", "SUPERKIT_AUTH_SIGNUP_MODE": "invite"}
+//
+// The file is read from SUPERKIT_CONFIG_FILE, or config.json if it exists.
+// Environment variables win over the file, which wins over the defaults.
 package conf
 
-// Here goes your application config
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultFile = "config.json"
+
+type Config struct {
+	Env    string `env:"SUPERKIT_ENV"`
+	Secret string `env:"SUPERKIT_SECRET" secret:"true"`
+	// AppURL is the public URL of the application, used for links in
+	// emails and OIDC redirects.
+	AppURL  string `env:"SUPERKIT_APP_URL"`
+	HTTP    HTTP
+	Log     Log
+	Metrics Metrics
+	DB      DB
+	Auth    Auth
+	Mail    Mail
+	Plugins Plugins
+}
+
+type HTTP struct {
+	ListenAddr        string        `env:"HTTP_LISTEN_ADDR" default:":3000"`
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" default:"15s"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"2m"`
+	ShutdownTimeout   time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" default:"15s"`
+	TLSCertFile       string        `env:"HTTP_TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"HTTP_TLS_KEY_FILE"`
+	// TLSSelfSigned serves TLS with a self-signed certificate during
+	// development.
+	TLSSelfSigned bool `env:"HTTP_TLS_SELF_SIGNED"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse
+	// proxies in front of the application. The client address is only
+	// read from X-Forwarded-For and X-Real-IP if they come from these.
+	TrustedProxies []string `env:"HTTP_TRUSTED_PROXIES"`
+}
+
+type Log struct {
+	// Format is json or text. It defaults to json in production and text
+	// everywhere else.
+	Format string `env:"LOG_FORMAT"`
+	Level  string `env:"LOG_LEVEL" default:"info"`
+}
+
+// SlogLevel returns the parsed level, info if it is invalid.
+func (cfg Log) SlogLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+type Metrics struct {
+	// Token protects /metrics. Scrapers must send it as a bearer token.
+	// Without it the metrics are public.
+	Token string `env:"METRICS_TOKEN" secret:"true"`
+}
+
+type DB struct {
+	Driver   string `env:"DB_DRIVER" default:"sqlite3"`
+	Name     string `env:"DB_NAME"`
+	User     string `env:"DB_USER"`
+	Password string `env:"DB_PASSWORD" secret:"true"`
+	Host     string `env:"DB_HOST"`
+}
+
+type Auth struct {
+	SessionExpiryInHours           int      `env:"SUPERKIT_AUTH_SESSION_EXPIRY_IN_HOURS" default:"48"`
+	SkipVerify                     bool     `env:"SUPERKIT_AUTH_SKIP_VERIFY"`
+	RedirectAfterLogin             string   `env:"SUPERKIT_AUTH_REDIRECT_AFTER_LOGIN" default:"/profile"`
+	SignupMode                     string   `env:"SUPERKIT_AUTH_SIGNUP_MODE" default:"open"`
+	InviteExpiryInDays             int      `env:"SUPERKIT_AUTH_INVITE_EXPIRY_IN_DAYS" default:"14"`
+	EmailVerificationExpiryInHours int      `env:"SUPERKIT_AUTH_EMAIL_VERIFICATION_EXPIRY_IN_HOURS" default:"1"`
+	PasswordResetExpiryInHours     int      `env:"SUPERKIT_AUTH_PASSWORD_RESET_EXPIRY_IN_HOURS" default:"1"`
+	MagicLinkExpiryInMinutes       int      `env:"SUPERKIT_AUTH_MAGIC_LINK_EXPIRY_IN_MINUTES" default:"15"`
+	BcryptCost                     int      `env:"SUPERKIT_AUTH_BCRYPT_COST" default:"10"`
+	BreachedPasswordsFile          string   `env:"SUPERKIT_AUTH_BREACHED_PASSWORDS_FILE"`
+	LoginMaxAttempts               int      `env:"SUPERKIT_AUTH_LOGIN_MAX_ATTEMPTS" default:"5"`
+	LoginMaxAttemptsPerIP          int      `env:"SUPERKIT_AUTH_LOGIN_MAX_ATTEMPTS_PER_IP" default:"20"`
+	LoginAttemptWindowInMinutes    int      `env:"SUPERKIT_AUTH_LOGIN_ATTEMPT_WINDOW_IN_MINUTES" default:"60"`
+	LockoutMinutes                 int      `env:"SUPERKIT_AUTH_LOCKOUT_MINUTES" default:"1"`
+	LockoutMaxMinutes              int      `env:"SUPERKIT_AUTH_LOCKOUT_MAX_MINUTES" default:"60"`
+	UnlockExpiryInHours            int      `env:"SUPERKIT_AUTH_UNLOCK_EXPIRY_IN_HOURS" default:"24"`
+	TwoFactorRequiredRoles         []string `env:"SUPERKIT_AUTH_2FA_REQUIRED_ROLES" default:"staff,admin"`
+	TOTPIssuer                     string   `env:"SUPERKIT_AUTH_TOTP_ISSUER" default:"superkit"`
+	// OIDCProviders lists the names of the login providers. Each one is
+	// configured with the SUPERKIT_AUTH_OIDC_<NAME>_* variables.
+	OIDCProviders []string `env:"SUPERKIT_AUTH_OIDC_PROVIDERS"`
+	OIDC          []OIDCProvider
+}
+
+type OIDCProvider struct {
+	Name         string
+	Label        string `env:"LABEL"`
+	Issuer       string `env:"ISSUER"`
+	ClientID     string `env:"CLIENT_ID"`
+	ClientSecret string `env:"CLIENT_SECRET" secret:"true"`
+	Scopes       string `env:"SCOPES" default:"openid email profile"`
+	AuthURL      string `env:"AUTH_URL"`
+	TokenURL     string `env:"TOKEN_URL"`
+	UserInfoURL  string `env:"USERINFO_URL"`
+}
+
+type Mail struct {
+	// Transport is smtp, maildir or memory. It defaults to smtp in
+	// production and maildir everywhere else. Development always uses the
+	// outbox.
+	Transport    string `env:"SUPERKIT_MAIL_TRANSPORT"`
+	From         string `env:"SUPERKIT_MAIL_FROM" default:"noreply@localhost"`
+	Outbox       string `env:"SUPERKIT_MAIL_OUTBOX" default:"tmp/outbox"`
+	Maildir      string `env:"SUPERKIT_MAIL_MAILDIR" default:"tmp/mail"`
+	SMTPHost     string `env:"SUPERKIT_MAIL_SMTP_HOST" default:"localhost"`
+	SMTPPort     string `env:"SUPERKIT_MAIL_SMTP_PORT" default:"587"`
+	SMTPUsername string `env:"SUPERKIT_MAIL_SMTP_USERNAME"`
+	SMTPPassword string `env:"SUPERKIT_MAIL_SMTP_PASSWORD" secret:"true"`
+	SMTPTLS      bool   `env:"SUPERKIT_MAIL_SMTP_TLS"`
+}
+
+type Plugins struct {
+	// Disabled lists the names of the plugins to turn off.
+	Disabled []string `env:"PLUGINS_DISABLED"`
+}
+
+// Load reads the configuration from the environment and the optional
+// file and validates it. The error lists every invalid setting.
+func Load() (*Config, error) {
+	file, err := readFile()
+	if err != nil {
+		return nil, err
+	}
+	lookup := func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := file[name]
+		return value, ok
+	}
+
+	cfg := &Config{}
+	var errs []error
+	load(reflect.ValueOf(cfg).Elem(), "", lookup, &errs)
+	for _, name := range cfg.Auth.OIDCProviders {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+		provider := OIDCProvider{Name: name}
+		load(reflect.ValueOf(&provider).Elem(), "SUPERKIT_AUTH_OIDC_"+strings.ToUpper(name)+"_", lookup, &errs)
+		if len(provider.Label) == 0 {
+			provider.Label = name
+		}
+		provider.Issuer = strings.TrimSuffix(provider.Issuer, "/")
+		cfg.Auth.OIDC = append(cfg.Auth.OIDC, provider)
+	}
+	if len(errs) == 0 {
+		errs = cfg.validate()
+	}
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// BaseURL returns the public URL of the application without a trailing
+// slash.
+func (cfg *Config) BaseURL() string {
+	if len(cfg.AppURL) > 0 {
+		return strings.TrimSuffix(cfg.AppURL, "/")
+	}
+	return "http://localhost" + cfg.HTTP.ListenAddr
+}
+
+func (cfg *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, name, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{name}, args...)...))
+		}
+	}
+	check(slices.Contains([]string{"development", "production", "test"}, cfg.Env), "SUPERKIT_ENV",
+		"must be development, production or test, got %q", cfg.Env)
+	check(len(cfg.Secret) >= 32, "SUPERKIT_SECRET", "must be at least 32 characters long")
+	if len(cfg.AppURL) > 0 {
+		u, err := url.Parse(cfg.AppURL)
+		check(err == nil && len(u.Scheme) > 0 && len(u.Host) > 0, "SUPERKIT_APP_URL", "must be an absolute URL like https://example.com")
+	}
+
+	check(len(cfg.HTTP.ListenAddr) > 0, "HTTP_LISTEN_ADDR", "must not be empty")
+	for name, d := range map[string]time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT": cfg.HTTP.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":        cfg.HTTP.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":       cfg.HTTP.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        cfg.HTTP.IdleTimeout,
+		"HTTP_SHUTDOWN_TIMEOUT":    cfg.HTTP.ShutdownTimeout,
+	} {
+		check(d > 0, name, "must be positive")
+	}
+	check((len(cfg.HTTP.TLSCertFile) > 0) == (len(cfg.HTTP.TLSKeyFile) > 0), "HTTP_TLS_CERT_FILE",
+		"must be set together with HTTP_TLS_KEY_FILE")
+	for name, path := range map[string]string{
+		"HTTP_TLS_CERT_FILE":                    cfg.HTTP.TLSCertFile,
+		"HTTP_TLS_KEY_FILE":                     cfg.HTTP.TLSKeyFile,
+		"SUPERKIT_AUTH_BREACHED_PASSWORDS_FILE": cfg.Auth.BreachedPasswordsFile,
+	} {
+		if len(path) > 0 {
+			_, err := os.Stat(path)
+			check(err == nil, name, "%v", err)
+		}
+	}
+
+	for _, proxy := range cfg.HTTP.TrustedProxies {
+		_, prefixErr := netip.ParsePrefix(proxy)
+		_, addrErr := netip.ParseAddr(proxy)
+		check(prefixErr == nil || addrErr == nil, "HTTP_TRUSTED_PROXIES",
+			"must be IP addresses or CIDR ranges, got %q", proxy)
+	}
+
+	check(slices.Contains([]string{"", "json", "text"}, cfg.Log.Format), "LOG_FORMAT",
+		"must be json or text, got %q", cfg.Log.Format)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(cfg.Log.Level)) == nil, "LOG_LEVEL",
+		"must be debug, info, warn or error, got %q", cfg.Log.Level)
+
+	check(cfg.DB.Driver == "sqlite3", "DB_DRIVER", "only sqlite3 is supported, got %q", cfg.DB.Driver)
+	check(len(cfg.DB.Name) > 0, "DB_NAME", "must not be empty")
+
+	auth := cfg.Auth
+	check(slices.Contains([]string{"open", "invite"}, auth.SignupMode), "SUPERKIT_AUTH_SIGNUP_MODE",
+		"must be open or invite, got %q", auth.SignupMode)
+	check(auth.BcryptCost >= 4 && auth.BcryptCost <= 31, "SUPERKIT_AUTH_BCRYPT_COST", "must be between 4 and 31")
+	check(strings.HasPrefix(auth.RedirectAfterLogin, "/"), "SUPERKIT_AUTH_REDIRECT_AFTER_LOGIN", "must be a path starting with /")
+	for name, n := range map[string]int{
+		"SUPERKIT_AUTH_SESSION_EXPIRY_IN_HOURS":            auth.SessionExpiryInHours,
+		"SUPERKIT_AUTH_INVITE_EXPIRY_IN_DAYS":              auth.InviteExpiryInDays,
+		"SUPERKIT_AUTH_EMAIL_VERIFICATION_EXPIRY_IN_HOURS": auth.EmailVerificationExpiryInHours,
+		"SUPERKIT_AUTH_PASSWORD_RESET_EXPIRY_IN_HOURS":     auth.PasswordResetExpiryInHours,
+		"SUPERKIT_AUTH_MAGIC_LINK_EXPIRY_IN_MINUTES":       auth.MagicLinkExpiryInMinutes,
+		"SUPERKIT_AUTH_LOGIN_MAX_ATTEMPTS":                 auth.LoginMaxAttempts,
+		"SUPERKIT_AUTH_LOGIN_MAX_ATTEMPTS_PER_IP":          auth.LoginMaxAttemptsPerIP,
+		"SUPERKIT_AUTH_LOGIN_ATTEMPT_WINDOW_IN_MINUTES":    auth.LoginAttemptWindowInMinutes,
+		"SUPERKIT_AUTH_LOCKOUT_MINUTES":                    auth.LockoutMinutes,
+		"SUPERKIT_AUTH_LOCKOUT_MAX_MINUTES":                auth.LockoutMaxMinutes,
+		"SUPERKIT_AUTH_UNLOCK_EXPIRY_IN_HOURS":             auth.UnlockExpiryInHours,
+	} {
+		check(n > 0, name, "must be positive")
+	}
+	for _, provider := range auth.OIDC {
+		prefix := "SUPERKIT_AUTH_OIDC_" + strings.ToUpper(provider.Name) + "_"
+		check(len(provider.ClientID) > 0, prefix+"CLIENT_ID", "must not be empty")
+		check(len(provider.Issuer) > 0 || len(provider.AuthURL) > 0 && len(provider.TokenURL) > 0, prefix+"ISSUER",
+			"must be set, or %sAUTH_URL and %sTOKEN_URL", prefix, prefix)
+	}
+
+	check(slices.Contains([]string{"", "smtp", "maildir", "memory"}, cfg.Mail.Transport), "SUPERKIT_MAIL_TRANSPORT",
+		"must be smtp, maildir or memory, got %q", cfg.Mail.Transport)
+
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+	return errs
+}
+
+// Print writes the effective configuration as environment variables, in
+// the order of the Config fields. Secrets are redacted.
+func (cfg *Config) Print(w io.Writer) {
+	printFields(w, reflect.ValueOf(cfg).Elem(), "")
+	for _, provider := range cfg.Auth.OIDC {
+		printFields(w, reflect.ValueOf(provider), "SUPERKIT_AUTH_OIDC_"+strings.ToUpper(provider.Name)+"_")
+	}
+}
+
+func printFields(w io.Writer, val reflect.Value, prefix string) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			printFields(w, val.Field(i), prefix)
+			continue
+		}
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		value := format(val.Field(i))
+		if field.Tag.Get("secret") == "true" && len(value) > 0 {
+			value = "[redacted]"
+		}
+		fmt.Fprintf(w, "%s%s=%s\n", prefix, name, value)
+	}
+}
+
+func format(val reflect.Value) string {
+	switch v := val.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// load sets the fields of the struct val from the variables named by their
+// env tags with the given prefix, or from their defaults.
+func load(val reflect.Value, prefix string, lookup func(string) (string, bool), errs *[]error) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			load(val.Field(i), prefix, lookup, errs)
+			continue
+		}
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		name = prefix + name
+		value, ok := lookup(name)
+		if !ok {
+			value = field.Tag.Get("default")
+		}
+		if err := set(val.Field(i), strings.TrimSpace(value)); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+}
+
+func set(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, use a value like 30s or 2m", value)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		if len(value) == 0 {
+			field.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, use true or false", value)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// readFile reads the variables of the optional config file. Values may be
+// strings, numbers or booleans.
+func readFile() (map[string]string, error) {
+	path, explicit := os.LookupEnv("SUPERKIT_CONFIG_FILE")
+	if !explicit {
+		path = defaultFile
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SUPERKIT_CONFIG_FILE: %v", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	file := make(map[string]string, len(values))
+	for name, value := range values {
+		switch value.(type) {
+		case string, float64, bool:
+			file[name] = fmt.Sprint(value)
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or boolean", path, name)
+		}
+	}
+	return file, nil
+}
@@ -1,8 +1,8 @@
 package db
 
 import (
-	"log"
-	"os"
+	"fmt"
+	"gothstack/app/conf"
 
 	"github.com/anthdm/superkit/db"
 
@@ -21,19 +21,30 @@ func Get() *gorm.DB {
 	return dbInstance
 }
 
-func init() {
+// Close closes the connections of the DB instance.
+func Close() error {
+	sqlDB, err := dbInstance.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Init opens the DB instance with the given configuration. It must be
+// called before Get.
+func Init(cfg conf.DB) error {
 	// Create a default *sql.DB exposed by the superkit/db package
 	// based on the given configuration.
 	config := db.Config{
-		Driver:   os.Getenv("DB_DRIVER"),
-		Name:     os.Getenv("DB_NAME"),
-		Password: os.Getenv("DB_PASSWORD"),
-		User:     os.Getenv("DB_USER"),
-		Host:     os.Getenv("DB_HOST"),
+		Driver:   cfg.Driver,
+		Name:     cfg.Name,
+		Password: cfg.Password,
+		User:     cfg.User,
+		Host:     cfg.Host,
 	}
 	dbinst, err := db.NewSQL(config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	// Based on the driver create the corresponding DB instance.
 	// By default, the SuperKit boilerplate comes with a pre-configured
@@ -52,9 +63,7 @@ func init() {
 	case db.DriverMysql:
 		// ...
 	default:
-		log.Fatal("invalid driver:", config.Driver)
-	}
-	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("invalid driver: %s", config.Driver)
 	}
+	return err
 }
@@ -2,8 +2,7 @@ package handlers
 
 import (
 	"gothstack/app/types"
-
-	"github.com/anthdm/superkit/kit"
+	"gothstack/kit"
 )
 
 func HandleAuthentication(kit *kit.Kit) (kit.Auth, error) {
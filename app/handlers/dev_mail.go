@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"gothstack/app/views/dev"
+	"gothstack/kit"
+	"gothstack/kit/mail"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HandleDevMailIndex lists the emails captured in the development outbox.
+func HandleDevMailIndex(kit *kit.Kit) error {
+	outbox, ok := mail.CurrentOutbox()
+	if !ok {
+		return kit.Error(http.StatusNotFound, "The outbox is only available in development", nil)
+	}
+	messages, err := outbox.List()
+	if err != nil {
+		return err
+	}
+	return kit.Render(dev.MailIndex(messages))
+}
+
+// HandleDevMailShow shows the headers and bodies of a captured email.
+func HandleDevMailShow(kit *kit.Kit) error {
+	msg, err := devMailMessage(kit)
+	if errors.Is(err, mail.ErrMessageNotFound) {
+		return kit.Redirect(http.StatusSeeOther, "/dev/mail")
+	}
+	if err != nil {
+		return err
+	}
+	return kit.Render(dev.MailShow(msg))
+}
+
+// HandleDevMailHTML serves the HTML body of a captured email to the
+// iframe on the message page. Links open in the top window so
+// verification and reset links work as they would in a mail client.
+func HandleDevMailHTML(kit *kit.Kit) error {
+	msg, err := devMailMessage(kit)
+	if errors.Is(err, mail.ErrMessageNotFound) {
+		return kit.Error(http.StatusNotFound, "Message not found", err)
+	}
+	if err != nil {
+		return err
+	}
+	html := msg.HTML
+	if i := strings.Index(html, "<head>"); i >= 0 {
+		html = html[:i+len("<head>")] + `<base target="_top">` + html[i+len("<head>"):]
+	}
+	kit.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = kit.Response.Write([]byte(html))
+	return err
+}
+
+// HandleDevMailDelete removes all captured emails.
+func HandleDevMailDelete(kit *kit.Kit) error {
+	outbox, ok := mail.CurrentOutbox()
+	if !ok {
+		return kit.Error(http.StatusNotFound, "The outbox is only available in development", nil)
+	}
+	if err := outbox.Clear(); err != nil {
+		return err
+	}
+	if err := kit.FlashInfo("The outbox is empty."); err != nil {
+		return err
+	}
+	return kit.Redirect(http.StatusSeeOther, "/dev/mail")
+}
+
+func devMailMessage(kit *kit.Kit) (mail.OutboxMessage, error) {
+	outbox, ok := mail.CurrentOutbox()
+	if !ok {
+		return mail.OutboxMessage{}, mail.ErrMessageNotFound
+	}
+	return outbox.Get(chi.URLParam(kit.Request, "id"))
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// HandleHealthz tells the orchestrator that the process is alive. It
+// doesn't check any dependency, so a database outage doesn't get the
+// container restarted.
+func HandleHealthz(kit *kit.Kit) error {
+	return kit.Text(http.StatusOK, "ok")
+}
+
+// HandleReadyz tells the orchestrator whether the application can serve
+// requests, which needs the database.
+func HandleReadyz(kit *kit.Kit) error {
+	ctx, cancel := context.WithTimeout(kit.Request.Context(), 2*time.Second)
+	defer cancel()
+	sqlDB, err := db.Get().DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		slog.Warn("readiness check failed", "err", err)
+		return kit.Text(http.StatusServiceUnavailable, "database unavailable")
+	}
+	return kit.Text(http.StatusOK, "ok")
+}
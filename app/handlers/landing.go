@@ -2,8 +2,7 @@ package handlers
 
 import (
 	"gothstack/app/views/landing"
-
-	"github.com/anthdm/superkit/kit"
+	"gothstack/kit"
 )
 
 func HandleLandingIndex(kit *kit.Kit) error {
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"database/sql"
+	"gothstack/app/db"
+	"gothstack/kit/event"
+	"gothstack/kit/metrics"
+)
+
+// Register the metrics that are read when /metrics is scraped. Request
+// metrics are recorded by metrics.Middleware and plugins register their
+// own counters, like the orders placed.
+func RegisterMetrics() {
+	stat := func(fn func(sql.DBStats) float64) func() float64 {
+		return func() float64 {
+			sqlDB, err := db.Get().DB()
+			if err != nil {
+				return 0
+			}
+			return fn(sqlDB.Stats())
+		}
+	}
+	metrics.NewGaugeFunc("db_open_connections", "Open database connections, in use or idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	metrics.NewGaugeFunc("db_in_use_connections", "Database connections in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	metrics.NewGaugeFunc("db_idle_connections", "Idle database connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	metrics.NewGaugeFunc("db_max_open_connections", "Maximum number of open database connections, 0 for no limit.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	metrics.NewCounterFunc("db_wait_count_total", "Times a query waited for a free database connection.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	metrics.NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting for a free database connection.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+
+	metrics.NewGaugeFunc("event_queue_depth", "Emitted events waiting to be dispatched to their handlers.",
+		func() float64 { return float64(event.Len()) })
+}
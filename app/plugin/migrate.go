@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+
+	"github.com/pressly/goose/v3"
+)
+
+// Migrations are run with goose from the files the plugins embed, so
+// databases migrated with the goose command line tool carry on.
+
+// NewMigrator returns a goose provider for the migrations of the enabled
+// plugins. Migrations of a plugin that is enabled later are applied even
+// if newer ones already were.
+func NewMigrator(db *sql.DB) (*goose.Provider, error) {
+	fsys, err := migrations()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectSQLite3, db, fsys,
+		goose.WithAllowOutofOrder(true),
+		goose.WithDisableGlobalRegistry(true),
+	)
+}
+
+// MigrationPlugin returns the name of the enabled plugin the migration
+// file at the given goose source path belongs to.
+func MigrationPlugin(source string) string {
+	name := path.Base(source)
+	for _, p := range enabled {
+		if fsys := p.Migrations(); fsys != nil {
+			if _, err := fs.Stat(fsys, name); err == nil {
+				return p.Name()
+			}
+		}
+	}
+	return ""
+}
+
+// migrations merges the migrations of the enabled plugins into one FS,
+// as goose reads them from a single directory.
+func migrations() (migrationFS, error) {
+	files := migrationFS{}
+	plugins := map[int64]string{}
+	for _, p := range enabled {
+		fsys := p.Migrations()
+		if fsys == nil {
+			continue
+		}
+		names, err := fs.Glob(fsys, "*.sql")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			version, err := goose.NumericComponent(name)
+			if err != nil {
+				return nil, fmt.Errorf("migration %s of plugin %s: the name must start with a version, like 1_create_users.sql", name, p.Name())
+			}
+			if other, ok := plugins[version]; ok {
+				return nil, fmt.Errorf("migration %s of plugin %s has the same version as a migration of plugin %s", name, p.Name(), other)
+			}
+			plugins[version] = p.Name()
+			files[name] = fsys
+		}
+	}
+	return files, nil
+}
+
+// migrationFS maps the name of a migration file to the FS of its plugin.
+type migrationFS map[string]fs.FS
+
+func (m migrationFS) Open(name string) (fs.File, error) {
+	fsys, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fsys.Open(name)
+}
+
+// ReadDir lists the migration files at the root, which is all goose
+// reads, sorted by version.
+func (m migrationFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, 0, len(m))
+	for name, fsys := range m {
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		va, _ := goose.NumericComponent(a.Name())
+		vb, _ := goose.NumericComponent(b.Name())
+		return cmp.Compare(va, vb)
+	})
+	return entries, nil
+}
@@ -0,0 +1,194 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gothstack/app/conf"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pressly/goose/v3"
+)
+
+// testPlugin is a plugin with the given migrations.
+type testPlugin struct {
+	Base
+	name       string
+	migrations fs.FS
+}
+
+func (p testPlugin) Name() string      { return p.name }
+func (p testPlugin) Migrations() fs.FS { return p.migrations }
+
+func migration(table string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(
+		"-- +goose Up\nCREATE TABLE " + table + " (id INTEGER PRIMARY KEY);\n" +
+			"-- +goose Down\nDROP TABLE " + table + ";\n",
+	)}
+}
+
+// enableTestPlugins enables the plugins and returns a new in-memory
+// database.
+func enableTestPlugins(t *testing.T, disabled []string, plugins ...Plugin) *sql.DB {
+	t.Helper()
+	cfg := &conf.Config{Plugins: conf.Plugins{Disabled: disabled}}
+	if err := Enable(cfg, plugins); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func sourceNames(results []*goose.MigrationResult) []string {
+	var names []string
+	for _, result := range results {
+		names = append(names, MigrationPlugin(result.Source.Path)+"/"+result.Source.Path)
+	}
+	return names
+}
+
+var (
+	users = testPlugin{name: "users", migrations: fstest.MapFS{
+		"1_create_users.sql":    migration("users"),
+		"3_create_sessions.sql": migration("sessions"),
+		"README.md":             &fstest.MapFile{Data: []byte("not a migration")},
+	}}
+	orders = testPlugin{name: "orders", migrations: fstest.MapFS{
+		"2_create_orders.sql": migration("orders"),
+		"4_create_items.sql":  migration("items"),
+	}}
+	nav = testPlugin{name: "nav"}
+)
+
+func TestMigrateUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := enableTestPlugins(t, nil, users, orders, nav)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"users/1_create_users.sql",
+		"orders/2_create_orders.sql",
+		"users/3_create_sessions.sql",
+		"orders/4_create_items.sql",
+	}
+	if got := sourceNames(results); !slices.Equal(got, want) {
+		t.Errorf("Up applied %v, want %v", got, want)
+	}
+
+	results, err = migrator.Up(ctx)
+	if err != nil || len(results) != 0 {
+		t.Errorf("second Up = %v, %v, want nothing to apply", sourceNames(results), err)
+	}
+
+	result, err := migrator.Down(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := MigrationPlugin(result.Source.Path) + "/" + result.Source.Path; got != "orders/4_create_items.sql" {
+		t.Errorf("Down rolled back %s, want orders/4_create_items.sql", got)
+	}
+	if _, err := db.Exec("SELECT * FROM items"); err == nil {
+		t.Error("the items table still exists after Down")
+	}
+
+	results, err = migrator.DownTo(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("DownTo(0) rolled back %v, want 3 migrations", sourceNames(results))
+	}
+	if _, err := migrator.Down(ctx); !errors.Is(err, goose.ErrNoNextVersion) {
+		t.Errorf("Down without applied migrations = %v, want ErrNoNextVersion", err)
+	}
+}
+
+func TestMigrateLaterEnabledPlugin(t *testing.T) {
+	ctx := context.Background()
+	db := enableTestPlugins(t, []string{"orders"}, users, orders)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enabling the plugin later applies its older migrations too.
+	if err := Enable(&conf.Config{}, []Plugin{users, orders}); err != nil {
+		t.Fatal(err)
+	}
+	migrator, err = NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pending []string
+	for _, status := range statuses {
+		if status.State == goose.StatePending {
+			pending = append(pending, status.Source.Path)
+		}
+	}
+	if want := []string{"2_create_orders.sql", "4_create_items.sql"}; !slices.Equal(pending, want) {
+		t.Errorf("pending migrations = %v, want %v", pending, want)
+	}
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Up applied %v, want the 2 migrations of orders", sourceNames(results))
+	}
+}
+
+func TestNewMigratorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins []Plugin
+		err     string
+	}{
+		{
+			name: "same version in two plugins",
+			plugins: []Plugin{users, testPlugin{name: "clash", migrations: fstest.MapFS{
+				"1_create_clash.sql": migration("clash"),
+			}}},
+			err: "same version as a migration of plugin users",
+		},
+		{
+			name: "name without a version",
+			plugins: []Plugin{testPlugin{name: "bad", migrations: fstest.MapFS{
+				"create_bad.sql": migration("bad"),
+			}}},
+			err: "the name must start with a version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := enableTestPlugins(t, nil, tt.plugins...)
+			_, err := NewMigrator(db)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("NewMigrator error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Package plugin defines how features plug into the application. A plugin
+// brings its routes, event handlers, migrations, navigation entries, seeds
+// and background jobs, and the application only lists it in app.Plugins.
+package plugin
+
+import (
+	"context"
+	"gothstack/app/conf"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"io/fs"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Plugin is a feature of the application. Embed Base to only implement
+// the methods the plugin needs.
+type Plugin interface {
+	// Name identifies the plugin, e.g. in PLUGINS_DISABLED.
+	Name() string
+	// Requires returns the names of the plugins this one depends on.
+	Requires() []string
+	// Init is called with the application before the routes are mounted.
+	// It may be called more than once, so registrations it makes must
+	// replace the earlier ones.
+	Init(app App) error
+	// Routes mounts the routes of the plugin.
+	Routes(router chi.Router, app App)
+	// Events returns the handlers of the plugin for emitted events.
+	Events() []Subscription
+	// Migrations returns the goose SQL migrations of the plugin, files
+	// named like 1_create_users.sql at the root of the FS, or nil. The
+	// versions must be unique across all plugins.
+	Migrations() fs.FS
+	// Nav returns the entries of the plugin in the navigation bar.
+	Nav() []NavItem
+	// Seed inserts the initial data of the plugin. It runs on every
+	// "app seed", so it must not insert the data twice.
+	Seed(ctx context.Context) error
+	// Jobs returns the background jobs of the plugin.
+	Jobs() []Job
+}
+
+// App is what plugins get from the application.
+type App struct {
+	Config *conf.Config
+	// Auth authenticates the routes of the plugin with
+	// kit.WithAuthentication.
+	Auth kit.AuthenticationConfig
+}
+
+// Subscription subscribes Handler to the events emitted to Topic.
+type Subscription struct {
+	Topic   string
+	Handler event.HandlerFunc
+}
+
+// NavSection is the place of an entry in the navigation bar.
+type NavSection string
+
+const (
+	// NavPublic entries sit next to the brand.
+	NavPublic NavSection = "public"
+	// NavMain entries are grouped by plugin on the right.
+	NavMain NavSection = "main"
+	// NavAdmin entries are highlighted as staff tools.
+	NavAdmin NavSection = "admin"
+)
+
+// NavItem is an entry in the navigation bar.
+type NavItem struct {
+	Label   string
+	URL     string
+	Section NavSection
+	// Permission hides the entry from users without it. Empty shows the
+	// entry to everyone.
+	Permission string
+}
+
+// Job is a function that runs in the background every Interval, starting
+// one interval after the application starts.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Base implements every method of Plugin but Name with no-ops.
+type Base struct{}
+
+func (Base) Requires() []string                { return nil }
+func (Base) Init(app App) error                { return nil }
+func (Base) Routes(router chi.Router, app App) {}
+func (Base) Events() []Subscription            { return nil }
+func (Base) Migrations() fs.FS                 { return nil }
+func (Base) Nav() []NavItem                    { return nil }
+func (Base) Seed(ctx context.Context) error    { return nil }
+func (Base) Jobs() []Job                       { return nil }
@@ -0,0 +1,166 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"gothstack/app/conf"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// enabled holds the plugins selected by Enable, in the order they were
+// listed.
+var enabled []Plugin
+
+// subscriptions holds the event subscriptions made by Init, so calling it
+// again replaces them instead of subscribing the handlers twice.
+var subscriptions []event.Subscription
+
+// Enable selects the plugins that are not disabled with PLUGINS_DISABLED
+// and checks that the plugins they require are enabled too. It must be
+// called once at startup, before the other functions of the package.
+func Enable(cfg *conf.Config, plugins []Plugin) error {
+	names := map[string]bool{}
+	for _, p := range plugins {
+		if names[p.Name()] {
+			return fmt.Errorf("plugin %q is listed twice", p.Name())
+		}
+		names[p.Name()] = true
+	}
+	for _, name := range cfg.Plugins.Disabled {
+		if !names[name] {
+			return fmt.Errorf("PLUGINS_DISABLED: unknown plugin %q", name)
+		}
+	}
+
+	enabled = nil
+	for _, p := range plugins {
+		if !slices.Contains(cfg.Plugins.Disabled, p.Name()) {
+			enabled = append(enabled, p)
+		}
+	}
+	for _, p := range enabled {
+		for _, name := range p.Requires() {
+			if !IsEnabled(name) {
+				return fmt.Errorf("plugin %q requires the %q plugin, which is disabled or missing", p.Name(), name)
+			}
+		}
+	}
+	return nil
+}
+
+// Enabled returns the enabled plugins.
+func Enabled() []Plugin {
+	return enabled
+}
+
+// IsEnabled returns true if the plugin with the given name is enabled.
+func IsEnabled(name string) bool {
+	return slices.ContainsFunc(enabled, func(p Plugin) bool {
+		return p.Name() == name
+	})
+}
+
+// Init initializes the enabled plugins, mounts their routes and
+// subscribes their event handlers. The handlers subscribed by an earlier
+// call are unsubscribed first.
+func Init(router chi.Router, app App) error {
+	for _, p := range enabled {
+		if err := p.Init(app); err != nil {
+			return fmt.Errorf("plugin %s: %w", p.Name(), err)
+		}
+	}
+	for _, sub := range subscriptions {
+		event.Unsubscribe(sub)
+	}
+	subscriptions = nil
+	for _, p := range enabled {
+		p.Routes(router, app)
+		for _, sub := range p.Events() {
+			subscriptions = append(subscriptions, event.Subscribe(sub.Topic, sub.Handler))
+		}
+	}
+	return nil
+}
+
+// Nav returns the navigation entries of the enabled plugins in the given
+// section that the user may see, grouped by plugin.
+func Nav(auth kit.Auth, section NavSection) [][]NavItem {
+	var groups [][]NavItem
+	for _, p := range enabled {
+		var items []NavItem
+		for _, item := range p.Nav() {
+			if item.Section != section {
+				continue
+			}
+			if len(item.Permission) == 0 || auth.Can(item.Permission) {
+				items = append(items, item)
+			}
+		}
+		if len(items) > 0 {
+			groups = append(groups, items)
+		}
+	}
+	return groups
+}
+
+// Seed runs the seeds of the enabled plugins.
+func Seed(ctx context.Context) error {
+	for _, p := range enabled {
+		if err := p.Seed(ctx); err != nil {
+			return fmt.Errorf("plugin %s: seed: %w", p.Name(), err)
+		}
+	}
+	return nil
+}
+
+// StartJobs runs the jobs of the enabled plugins until ctx is done. The
+// returned function waits for running jobs to return.
+func StartJobs(ctx context.Context) (wait func()) {
+	var wg sync.WaitGroup
+	for _, p := range enabled {
+		for _, job := range p.Jobs() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				runJob(ctx, p.Name()+"."+job.Name, job)
+			}()
+		}
+	}
+	return wg.Wait
+}
+
+func runJob(ctx context.Context, name string, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			if err := runOnce(ctx, job); err != nil {
+				slog.Error("job failed", "job", name, "err", err)
+				continue
+			}
+			slog.Debug("job done", "job", name, "duration", time.Since(start))
+		}
+	}
+}
+
+// runOnce runs the job, turning a panic into an error so it doesn't
+// stop the application.
+func runOnce(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			err = fmt.Errorf("panic: %v", rvr)
+		}
+	}()
+	return job.Run(ctx)
+}
@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"context"
+	"gothstack/app/conf"
+	"gothstack/kit/event"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// eventPlugin counts the events emitted to its topic.
+type eventPlugin struct {
+	Base
+	topic    string
+	received *atomic.Int32
+}
+
+func (p eventPlugin) Name() string { return "events" }
+
+func (p eventPlugin) Events() []Subscription {
+	return []Subscription{{Topic: p.topic, Handler: func(ctx context.Context, e any) {
+		p.received.Add(1)
+	}}}
+}
+
+func TestInitSubscribesOnce(t *testing.T) {
+	p := eventPlugin{topic: "test.init.once", received: &atomic.Int32{}}
+	if err := Enable(&conf.Config{}, []Plugin{p}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := Init(chi.NewRouter(), App{Config: &conf.Config{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, sub := range subscriptions {
+			event.Unsubscribe(sub)
+		}
+		subscriptions = nil
+	})
+
+	event.Emit(p.topic, nil)
+	deadline := time.Now().Add(time.Second)
+	for p.received.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// Give a duplicate handler the time to run too.
+	time.Sleep(50 * time.Millisecond)
+	if got := p.received.Load(); got != 1 {
+		t.Errorf("the event was handled %d times, want once", got)
+	}
+}
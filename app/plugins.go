@@ -0,0 +1,34 @@
+package app
+
+import (
+	"errors"
+	"gothstack/app/conf"
+	"gothstack/app/plugin"
+	"gothstack/plugins/auth"
+	"gothstack/plugins/delivery"
+	"gothstack/plugins/helloworld"
+	"gothstack/plugins/reservation"
+)
+
+// Plugins are the features of the application. Add your plugin here to
+// mount its routes, subscribe its events, apply its migrations, show its
+// navigation entries and run its seeds and jobs. Plugins can be disabled
+// with PLUGINS_DISABLED, except auth, which authenticates every route.
+var Plugins = []plugin.Plugin{
+	auth.Plugin{},
+	delivery.Plugin{},
+	reservation.Plugin{},
+	helloworld.Plugin{},
+}
+
+// EnablePlugins enables the plugins that are not disabled in the
+// configuration.
+func EnablePlugins(cfg *conf.Config) error {
+	if err := plugin.Enable(cfg, Plugins); err != nil {
+		return err
+	}
+	if !plugin.IsEnabled("auth") {
+		return errors.New("the auth plugin can't be disabled, it authenticates every route")
+	}
+	return nil
+}
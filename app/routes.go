@@ -1,43 +1,57 @@
 package app
 
 import (
+	"crypto/subtle"
+	"gothstack/app/conf"
 	"gothstack/app/handlers"
+	"gothstack/app/plugin"
 	"gothstack/app/views/errors"
+	"gothstack/kit"
+	"gothstack/kit/metrics"
+	"gothstack/kit/middleware"
 	"gothstack/plugins/auth"
-	"gothstack/plugins/delivery"
-	"gothstack/plugins/helloworld"
-	"gothstack/plugins/reservation"
-	"log/slog"
+	"net/http"
 
-	"github.com/anthdm/superkit/kit"
-	"github.com/anthdm/superkit/kit/middleware"
+	"github.com/a-h/templ"
 	"github.com/go-chi/chi/v5"
-
-	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"gorm.io/gorm"
 )
 
 // Define your global middleware
 func InitializeMiddleware(router *chi.Mux) {
-	router.Use(chimiddleware.Logger)
-	router.Use(chimiddleware.Recoverer)
+	router.Use(metrics.Middleware)
+	router.Use(kit.WithRequestID)
+	router.Use(kit.WithRequestLogger)
+	router.Use(kit.WithRecover)
 	router.Use(middleware.WithRequest)
+	router.Use(kit.WithCSRF)
+	router.Use(kit.WithFlash)
 }
 
-// Define your routes in here
-func InitializeRoutes(router *chi.Mux) {
+// Define your routes in here. The routes of the plugins are mounted
+// from app.Plugins.
+func InitializeRoutes(router *chi.Mux, cfg *conf.Config) error {
+	// Health checks and metrics
+	//
+	// /healthz is the liveness probe and /readyz the readiness probe of
+	// the orchestrator. /metrics is scraped by Prometheus.
+	router.Get("/healthz", kit.Handler(handlers.HandleHealthz))
+	router.Get("/readyz", kit.Handler(handlers.HandleReadyz))
+	router.With(metricsAuth(cfg.Metrics.Token)).Handle("/metrics", metrics.Handler())
+
 	// Authentication plugin
 	//
-	// By default the auth plugin is active, to disable the auth plugin
-	// you will need to pass your own handler in the `AuthFunc`` field
-	// of the `kit.AuthenticationConfig`.
+	// The auth plugin authenticates the routes of the application and
+	// of the other plugins. To replace it you will need to pass your own
+	// handler in the `AuthFunc` field of the `kit.AuthenticationConfig`.
 	authConfig := kit.AuthenticationConfig{
-		AuthFunc:    auth.AuthenticateUser,
+		AuthFunc:    auth.Authenticate,
 		RedirectURL: "/login",
 	}
-	auth.InitializeRoutes(router, authConfig)
-	helloworld.InitRoutes(router, authConfig)
-	reservation.InitRoutes(router, authConfig)
-	delivery.InitRoutes(router, authConfig)
+	err := plugin.Init(router, plugin.App{Config: cfg, Auth: authConfig})
+	if err != nil {
+		return err
+	}
 
 	// Routes that "might" have an authenticated user
 	router.Group(func(app chi.Router) {
@@ -47,6 +61,19 @@ func InitializeRoutes(router *chi.Mux) {
 		app.Get("/", kit.Handler(handlers.HandleLandingIndex))
 	})
 
+	// Development tools
+	//
+	// Only registered when SUPERKIT_ENV is development.
+	if kit.IsDevelopment() {
+		router.Group(func(dev chi.Router) {
+			dev.Use(kit.WithAuthentication(authConfig, false))
+			dev.Get("/dev/mail", kit.Handler(handlers.HandleDevMailIndex))          // List captured emails
+			dev.Delete("/dev/mail", kit.Handler(handlers.HandleDevMailDelete))      // Clear the outbox
+			dev.Get("/dev/mail/{id}", kit.Handler(handlers.HandleDevMailShow))      // Show a captured email
+			dev.Get("/dev/mail/{id}/html", kit.Handler(handlers.HandleDevMailHTML)) // HTML body for the preview
+		})
+	}
+
 	// Authenticated routes
 	//
 	// Routes that "must" have an authenticated user or else they
@@ -58,16 +85,75 @@ func InitializeRoutes(router *chi.Mux) {
 		// Routes
 		// app.Get("/path", kit.Handler(myHandler.HandleIndex))
 	})
+	return nil
 }
 
 // NotFoundHandler that will be called when the requested path could
 // not be found.
 func NotFoundHandler(kit *kit.Kit) error {
-	return kit.Render(errors.Error404())
+	return kit.Error(http.StatusNotFound, "The page you are looking for does not exist", nil)
+}
+
+// ForbiddenHandler that will be called when the authenticated user lacks
+// the role or permission required by a route.
+func ForbiddenHandler(kit *kit.Kit) error {
+	return kit.Error(http.StatusForbidden, "You don't have permission to access this page", nil)
+}
+
+// CSRFFailureHandler that will be called when a state-changing request
+// has a missing or invalid CSRF token.
+func CSRFFailureHandler(kit *kit.Kit) error {
+	kit.Response.WriteHeader(http.StatusForbidden)
+	return kit.Render(errors.ErrorCSRF())
 }
 
 // ErrorHandler that will be called on errors return from application handlers.
+// Errors caused by the request, like a missing record, are logged as
+// client errors and show their message. Everything else is logged as an
+// internal server error and shows a generic page.
 func ErrorHandler(kit *kit.Kit, err error) {
-	slog.Error("internal server error", "err", err.Error(), "path", kit.Request.URL.Path)
-	kit.Render(errors.Error500())
+	// Copy the error, handlers may return shared instances.
+	httpErr := *kit.AsError(err)
+	httpErr.RequestID = kit.RequestID()
+	if httpErr.IsClientError() {
+		kit.Logger().Info("client error", "status", httpErr.Status, "err", err.Error(), "path", kit.Request.URL.Path)
+	} else {
+		kit.Logger().Error("internal server error", "status", httpErr.Status, "err", err.Error(), "path", kit.Request.URL.Path)
+	}
+	kit.Respond(httpErr.Status, httpErr, errorPage(&httpErr))
+}
+
+func errorPage(err *kit.Error) templ.Component {
+	switch {
+	case err.Status == http.StatusNotFound:
+		return errors.Error404(err.Message)
+	case err.Status == http.StatusForbidden:
+		return errors.Error403(err.Message)
+	case err.IsClientError():
+		return errors.ErrorPage(err.Status, err.Message, err.Fields)
+	default:
+		return errors.Error500()
+	}
+}
+
+// metricsAuth only lets scrapers with the given bearer token through,
+// if a token is set.
+func metricsAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ := kit.BearerToken(r)
+			if len(token) > 0 && subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RegisterErrors maps errors of libraries to status codes, so handlers
+// can return them as they are.
+func RegisterErrors() {
+	kit.MapError(gorm.ErrRecordNotFound, http.StatusNotFound, "The requested record does not exist")
 }
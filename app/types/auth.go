@@ -1,11 +1,14 @@
 package types
 
+import "slices"
+
 // AuthUser represents an user that might be authenticated.
 type AuthUser struct {
-	ID       uint
-	Email    string
-	LoggedIn bool
-	Role     string
+	ID          uint
+	Email       string
+	LoggedIn    bool
+	Role        string
+	Permissions []string
 }
 
 // Check should return true if the user is authenticated.
@@ -13,3 +16,14 @@ type AuthUser struct {
 func (user AuthUser) Check() bool {
 	return user.ID > 0 && user.LoggedIn
 }
+
+// HasRole should return true if the authenticated user has the given role.
+func (user AuthUser) HasRole(role string) bool {
+	return user.Check() && user.Role == role
+}
+
+// Can should return true if the authenticated user has been granted
+// the given permission.
+func (user AuthUser) Can(permission string) bool {
+	return user.Check() && slices.Contains(user.Permissions, permission)
+}
@@ -0,0 +1,33 @@
+package dev
+
+import "regexp"
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// textPart is a piece of a plain text body, either text or a link.
+type textPart struct {
+	Text string
+	Link bool
+}
+
+// linkify splits text into parts so links can be rendered clickable.
+func linkify(text string) []textPart {
+	var parts []textPart
+	last := 0
+	for _, loc := range linkPattern.FindAllStringIndex(text, -1) {
+		if loc[0] > last {
+			parts = append(parts, textPart{Text: text[last:loc[0]]})
+		}
+		parts = append(parts, textPart{Text: text[loc[0]:loc[1]], Link: true})
+		last = loc[1]
+	}
+	if last < len(text) {
+		parts = append(parts, textPart{Text: text[last:]})
+	}
+	return parts
+}
+
+// links returns the links in text in order of appearance.
+func links(text string) []string {
+	return linkPattern.FindAllString(text, -1)
+}
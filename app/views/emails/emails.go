@@ -0,0 +1,77 @@
+package emails
+
+import (
+	"bytes"
+	"context"
+	"gothstack/app/conf"
+	"gothstack/kit/mail"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"text/template"
+
+	"github.com/a-h/templ"
+)
+
+// Mailer sends the emails of a plugin. The HTML versions are templ
+// components built on Layout. The plain text versions are text/template
+// files the plugin embeds, because templ escapes HTML and would garble
+// links and quotes in plain text.
+type Mailer struct {
+	config *conf.Config
+	text   *template.Template
+}
+
+// NewMailer returns a mailer with the plain text templates in fsys that
+// match the pattern, e.g. "mail/*.txt".
+func NewMailer(cfg *conf.Config, fsys fs.FS, pattern string) *Mailer {
+	return &Mailer{
+		config: cfg,
+		text:   template.Must(template.New("").ParseFS(fsys, pattern)),
+	}
+}
+
+// Send renders the HTML component and the plain text template with the
+// given name, e.g. "verify_email.txt", and sends both to the recipient.
+// Event handlers have no caller to return an error to, so failures are
+// logged.
+func (m *Mailer) Send(ctx context.Context, to, subject string, html templ.Component, text string, data any) {
+	htmlBody, err := HTML(ctx, html)
+	if err != nil {
+		slog.Error("render email", "template", text, "err", err)
+		return
+	}
+	var textBody bytes.Buffer
+	if err := m.text.ExecuteTemplate(&textBody, text, data); err != nil {
+		slog.Error("render email", "template", text, "err", err)
+		return
+	}
+	err = mail.Send(ctx, mail.Message{
+		To:      []string{to},
+		Subject: subject,
+		Text:    textBody.String(),
+		HTML:    htmlBody,
+	})
+	if err != nil {
+		slog.Error("send email", "template", text, "to", to, "err", err)
+	}
+}
+
+// Link returns the absolute URL of path with the given query parameter,
+// based on SUPERKIT_APP_URL.
+func (m *Mailer) Link(path, param, value string) string {
+	link := m.config.BaseURL() + path
+	if len(param) > 0 {
+		link += "?" + url.Values{param: {value}}.Encode()
+	}
+	return link
+}
+
+// HTML renders the component to a string.
+func HTML(ctx context.Context, component templ.Component) (string, error) {
+	var buf bytes.Buffer
+	if err := component.Render(ctx, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gothstack/app/conf"
+	"gothstack/app/db"
+	"gothstack/app/plugin"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+const usage = `available commands:
+  config print     print the effective configuration
+  migrate up       apply the pending migrations of the enabled plugins
+  migrate down     roll back the latest applied migration
+  migrate reset    roll back all applied migrations
+  migrate status   list the migrations and when they were applied
+  seed             insert the initial data of the enabled plugins
+`
+
+// command runs a command given on the command line instead of the server.
+// It returns the exit code.
+func command(args []string, cfg *conf.Config, err error) int {
+	name := strings.Join(args, " ")
+	var run func(ctx context.Context) error
+	switch name {
+	case "config print":
+		if cfg != nil {
+			cfg.Print(os.Stdout)
+		}
+		if err != nil {
+			fmt.Println()
+			printConfigError(err)
+			return 1
+		}
+		return 0
+	case "migrate up":
+		run = migrateUp
+	case "migrate down":
+		run = migrateDown
+	case "migrate reset":
+		run = migrateReset
+	case "migrate status":
+		run = migrateStatus
+	case "seed":
+		run = plugin.Seed
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, %s", name, usage)
+		return 2
+	}
+
+	if err != nil {
+		printConfigError(err)
+		return 1
+	}
+	if err := setup(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	if err := run(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func printConfigError(err error) {
+	fmt.Fprintln(os.Stderr, "invalid configuration:")
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "  %s\n", line)
+	}
+}
+
+// migrator returns the goose provider for the migrations of the enabled
+// plugins.
+func migrator() (*goose.Provider, error) {
+	sqlDB, err := db.Get().DB()
+	if err != nil {
+		return nil, err
+	}
+	return plugin.NewMigrator(sqlDB)
+}
+
+func migrateUp(ctx context.Context) error {
+	provider, err := migrator()
+	if err != nil {
+		return err
+	}
+	results, err := provider.Up(ctx)
+	var partial *goose.PartialError
+	if errors.As(err, &partial) {
+		results = partial.Applied
+	}
+	printResults(results)
+	if err == nil && len(results) == 0 {
+		fmt.Println("no pending migrations")
+	}
+	return err
+}
+
+func migrateDown(ctx context.Context) error {
+	provider, err := migrator()
+	if err != nil {
+		return err
+	}
+	result, err := provider.Down(ctx)
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		fmt.Println("no applied migrations")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	printResults([]*goose.MigrationResult{result})
+	return nil
+}
+
+func migrateReset(ctx context.Context) error {
+	provider, err := migrator()
+	if err != nil {
+		return err
+	}
+	results, err := provider.DownTo(ctx, 0)
+	var partial *goose.PartialError
+	if errors.As(err, &partial) {
+		results = partial.Applied
+	}
+	printResults(results)
+	return err
+}
+
+func migrateStatus(ctx context.Context) error {
+	provider, err := migrator()
+	if err != nil {
+		return err
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLIED AT\tPLUGIN\tMIGRATION")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.State == goose.StateApplied {
+			appliedAt = status.AppliedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", appliedAt, plugin.MigrationPlugin(status.Source.Path), path.Base(status.Source.Path))
+	}
+	return w.Flush()
+}
+
+// printResults prints the migrations that were applied or rolled back.
+func printResults(results []*goose.MigrationResult) {
+	for _, result := range results {
+		verb := "applied"
+		if result.Direction == "down" {
+			verb = "rolled back"
+		}
+		fmt.Printf("%s %s/%s\n", verb, plugin.MigrationPlugin(result.Source.Path), path.Base(result.Source.Path))
+	}
+}
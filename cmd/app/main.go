@@ -1,20 +1,42 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"gothstack/app"
+	"gothstack/app/conf"
+	"gothstack/app/db"
+	"gothstack/app/plugin"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"gothstack/kit/mail"
 	"gothstack/public"
-	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/anthdm/superkit/kit"
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	kit.Setup()
+	cfg, err := conf.Load()
+	if len(os.Args) > 1 {
+		os.Exit(command(os.Args[1:], cfg, err))
+	}
+	if err != nil {
+		printConfigError(err)
+		os.Exit(1)
+	}
+	if err := setup(cfg); err != nil {
+		log.Fatal(err)
+	}
+
 	router := chi.NewMux()
 
 	app.InitializeMiddleware(router)
@@ -26,21 +48,129 @@ func main() {
 	}
 
 	kit.UseErrorHandler(app.ErrorHandler)
+	app.RegisterErrors()
+	app.RegisterMetrics()
+	kit.UseForbiddenHandler(app.ForbiddenHandler)
+	kit.UseCSRFFailureHandler(app.CSRFFailureHandler)
 	router.HandleFunc("/*", kit.Handler(app.NotFoundHandler))
 
-	app.InitializeRoutes(router)
-	app.RegisterEvents()
+	if err := app.InitializeRoutes(router, cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	listenAddr := cfg.HTTP.ListenAddr
+	server := &http.Server{
+		Addr:              listenAddr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+
+	// TLS is served from the certificate and key files if both are set.
+	// In development a self-signed certificate can be used instead.
+	certFile := cfg.HTTP.TLSCertFile
+	keyFile := cfg.HTTP.TLSKeyFile
+	useTLS := len(certFile) > 0 && len(keyFile) > 0
+	if !useTLS && kit.IsDevelopment() && cfg.HTTP.TLSSelfSigned {
+		cert, err := kit.SelfSignedCertificate("localhost", "127.0.0.1", "::1")
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		useTLS = true
+	}
 
-	listenAddr := os.Getenv("HTTP_LISTEN_ADDR")
 	// In development link the full Templ proxy url.
 	url := "http://localhost:7331"
-	if kit.IsProduction() {
-		url = fmt.Sprintf("http://localhost%s", listenAddr)
+	if kit.IsProduction() || useTLS {
+		scheme := "http"
+		if useTLS {
+			scheme = "https"
+		}
+		url = fmt.Sprintf("%s://localhost%s", scheme, listenAddr)
+	}
+
+	slog.Info("application running", "env", kit.Env(), "url", url)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The background jobs of the plugins stop with the first signal.
+	waitJobs := plugin.StartJobs(ctx)
+
+	errch := make(chan error, 1)
+	go func() {
+		if useTLS {
+			errch <- server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			errch <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errch:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process right away.
+	stop()
+
+	shutdown(server, cfg.HTTP.ShutdownTimeout, waitJobs)
+}
+
+// setup configures the packages of the application and enables the
+// plugins. It is shared by the server and the commands.
+func setup(cfg *conf.Config) error {
+	if err := kit.Configure(cfg.Env, cfg.Secret); err != nil {
+		return err
 	}
+	if err := kit.UseTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		return err
+	}
+	// The log package writes through the default logger as well.
+	slog.SetDefault(kit.NewLogger(os.Stdout, cfg.Log.Format, cfg.Log.SlogLevel()))
+	if err := app.EnablePlugins(cfg); err != nil {
+		return err
+	}
+	if err := db.Init(cfg.DB); err != nil {
+		return err
+	}
+	mail.Configure(mail.Config{
+		Transport: cfg.Mail.Transport,
+		From:      cfg.Mail.From,
+		Outbox:    cfg.Mail.Outbox,
+		Maildir:   cfg.Mail.Maildir,
+		SMTP: mail.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			TLS:      cfg.Mail.SMTPTLS,
+		},
+	})
+	return nil
+}
 
-	fmt.Printf("application running in %s at %s\n", kit.Env(), url)
+// shutdown stops accepting requests and waits for the running requests
+// and the event handlers they started to finish, waits for the background
+// jobs, then closes the database.
+func shutdown(server *http.Server, timeout time.Duration, waitJobs func()) {
+	slog.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	http.ListenAndServe(listenAddr, router)
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("server shutdown", "err", err)
+	}
+	if err := event.Shutdown(ctx); err != nil {
+		slog.Error("event stream shutdown", "err", err)
+	}
+	waitJobs()
+	if err := db.Close(); err != nil {
+		slog.Error("closing database", "err", err)
+	}
 }
 
 func staticDev() http.Handler {
@@ -0,0 +1,291 @@
+// Command mockoidc runs a minimal OpenID Connect issuer for local
+// development. It signs in anyone with the email entered on its login page.
+//
+// Configure the app with:
+//
+//	SUPERKIT_AUTH_OIDC_PROVIDERS=mock
+//	SUPERKIT_AUTH_OIDC_MOCK_LABEL="Mock provider"
+//	SUPERKIT_AUTH_OIDC_MOCK_ISSUER=http://localhost:9999
+//	SUPERKIT_AUTH_OIDC_MOCK_CLIENT_ID=superkit
+//	SUPERKIT_AUTH_OIDC_MOCK_CLIENT_SECRET=secret
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock"
+
+type authRequest struct {
+	ClientID      string
+	RedirectURI   string
+	Challenge     string
+	Nonce         string
+	Email         string
+	Name          string
+	EmailVerified bool
+	ExpiresAt     time.Time
+}
+
+type server struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]authRequest
+	tokens map[string]authRequest
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; max-width: 24rem; margin: 4rem auto;">
+	<h2>Mock identity provider</h2>
+	<form method="post">
+		{{ range $key, $values := .Query }}<input type="hidden" name="{{ $key }}" value="{{ index $values 0 }}">{{ end }}
+		<p><label>Email<br><input name="email" value="jane@example.com"></label></p>
+		<p><label>Name<br><input name="name" value="Jane Doe"></label></p>
+		<p><label><input type="checkbox" name="email_verified" value="true" checked> email verified</label></p>
+		<p><button>Sign in</button> <button name="deny" value="true">Deny</button></p>
+	</form>
+</body>
+</html>`))
+
+func main() {
+	addr := flag.String("addr", ":9999", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9999", "issuer URL")
+	clientID := flag.String("client-id", "superkit", "accepted client ID")
+	clientSecret := flag.String("client-secret", "secret", "accepted client secret, empty for public clients")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &server{
+		issuer:       strings.TrimSuffix(*issuer, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		key:          key,
+		codes:        map[string]authRequest{},
+		tokens:       map[string]authRequest{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET /authorize", s.handleAuthorizeIndex)
+	mux.HandleFunc("POST /authorize", s.handleAuthorizeCreate)
+	mux.HandleFunc("POST /token", s.handleToken)
+	mux.HandleFunc("GET /jwks", s.handleJWKS)
+	mux.HandleFunc("GET /userinfo", s.handleUserInfo)
+
+	fmt.Printf("mock oidc issuer running at %s\n", s.issuer)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"userinfo_endpoint":                     s.issuer + "/userinfo",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *server) handleAuthorizeIndex(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != s.clientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid client_id or response_type", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge_method") != "S256" || len(query.Get("code_challenge")) == 0 {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+	loginPage.Execute(w, map[string]any{"Query": query})
+}
+
+func (s *server) handleAuthorizeCreate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(r.PostForm.Get("redirect_uri"))
+	if err != nil || r.PostForm.Get("client_id") != s.clientID {
+		http.Error(w, "invalid redirect_uri or client_id", http.StatusBadRequest)
+		return
+	}
+	params := redirectURI.Query()
+	params.Set("state", r.PostForm.Get("state"))
+	if r.PostForm.Get("deny") == "true" {
+		params.Set("error", "access_denied")
+	} else {
+		code := randomString()
+		s.mu.Lock()
+		s.codes[code] = authRequest{
+			ClientID:      s.clientID,
+			RedirectURI:   redirectURI.String(),
+			Challenge:     r.PostForm.Get("code_challenge"),
+			Nonce:         r.PostForm.Get("nonce"),
+			Email:         r.PostForm.Get("email"),
+			Name:          r.PostForm.Get("name"),
+			EmailVerified: r.PostForm.Get("email_verified") == "true",
+			ExpiresAt:     time.Now().Add(time.Minute),
+		}
+		s.mu.Unlock()
+		params.Set("code", code)
+	}
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusSeeOther)
+}
+
+func (s *server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if clientID != s.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.clientSecret)) != 1 {
+		tokenError(w, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	req, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	if !ok || time.Now().After(req.ExpiresAt) || req.RedirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != req.Challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	first, last, _ := strings.Cut(req.Name, " ")
+	claims := jwt.MapClaims{
+		"iss":            s.issuer,
+		"sub":            subject(req.Email),
+		"aud":            req.ClientID,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"email":          req.Email,
+		"email_verified": req.EmailVerified,
+		"name":           req.Name,
+		"given_name":     first,
+		"family_name":    last,
+	}
+	if len(req.Nonce) > 0 {
+		claims["nonce"] = req.Nonce
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accessToken := randomString()
+	s.mu.Lock()
+	s.tokens[accessToken] = req
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	req, found := s.tokens[accessToken]
+	s.mu.Unlock()
+	if !ok || !found {
+		http.Error(w, "invalid access token", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sub":            subject(req.Email),
+		"email":          req.Email,
+		"email_verified": req.EmailVerified,
+		"name":           req.Name,
+	})
+}
+
+// subject derives a stable subject from the email so signing in twice with
+// the same email returns the same identity.
+func subject(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
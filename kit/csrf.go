@@ -0,0 +1,104 @@
+package kit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	// CSRFHeader is the request header htmx sends the CSRF token in.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField is the name of the hidden form field with the CSRF token.
+	CSRFField = "_csrf"
+
+	csrfSessionName = "csrf"
+	csrfTokenKey    = "token"
+)
+
+type CSRFKey struct{}
+
+// csrfDeferredKey holds the *csrfCheck of a request that sent a bearer
+// token instead of a CSRF token. Whether it needs one is only known once
+// WithAuthentication resolved how it is authenticated.
+type csrfDeferredKey struct{}
+
+type csrfCheck struct {
+	// exempt is set if the request was authenticated with a scoped
+	// credential like an API token, which doesn't use cookies.
+	exempt bool
+}
+
+var csrfFailureHandler = func(kit *Kit) error {
+	return kit.Text(http.StatusForbidden, "Invalid CSRF token")
+}
+
+// UseCSRFFailureHandler sets the handler that renders the response when
+// WithCSRF rejects a request.
+func UseCSRFFailureHandler(h HandlerFunc) { csrfFailureHandler = h }
+
+// WithCSRF protects state-changing requests against cross-site request
+// forgery. Every visitor gets a random token stored in the kit session,
+// which is available to views through the request context. POST, PUT,
+// PATCH and DELETE requests must send it back in the X-CSRF-Token header
+// or the _csrf form field. Requests authenticated with a token, see
+// ScopedAuth, don't use cookies and are not checked. Since that is only
+// known after WithAuthentication, requests with a bearer token are checked
+// by Handler.
+func WithCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kit := &Kit{
+			Response: w,
+			Request:  r,
+		}
+		sess := kit.GetSession(csrfSessionName)
+		token, _ := sess.Values[csrfTokenKey].(string)
+		if len(token) == 0 {
+			b := make([]byte, 32)
+			if _, err := rand.Read(b); err != nil {
+				errorHandler(kit, err)
+				return
+			}
+			token = base64.RawURLEncoding.EncodeToString(b)
+			sess.Values[csrfTokenKey] = token
+			if err := sess.Save(r, w); err != nil {
+				errorHandler(kit, err)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), CSRFKey{}, token)
+		if !csrfSafeMethod(r.Method) && !csrfTokenMatches(r, token) {
+			if _, ok := BearerToken(r); !ok {
+				kit.csrfFailure()
+				return
+			}
+			ctx = context.WithValue(ctx, csrfDeferredKey{}, &csrfCheck{})
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (kit *Kit) csrfFailure() {
+	if err := csrfFailureHandler(kit); err != nil {
+		errorHandler(kit, err)
+	}
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func csrfTokenMatches(r *http.Request, token string) bool {
+	sent := r.Header.Get(CSRFHeader)
+	if len(sent) == 0 {
+		sent = r.PostFormValue(CSRFField)
+	}
+	return len(sent) > 0 && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1
+}
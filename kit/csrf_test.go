@@ -0,0 +1,79 @@
+package kit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWithCSRF(t *testing.T) {
+	if err := Configure("test", strings.Repeat("s", 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bearer "valid" authenticates like an API token, any other request
+	// falls back to the cookie session.
+	config := AuthenticationConfig{
+		AuthFunc: func(kit *Kit) (Auth, error) {
+			if token, _ := BearerToken(kit.Request); token == "valid" {
+				return testAuth{permission: "orders.create", scoped: true}, nil
+			}
+			return testAuth{permission: "orders.create"}, nil
+		},
+	}
+	var token string
+	handler := WithCSRF(WithAuthentication(config, false)(
+		WithPermission("orders.create")(Handler(func(kit *Kit) error {
+			token, _ = kit.Request.Context().Value(CSRFKey{}).(string)
+			return kit.Text(http.StatusOK, "ok")
+		})),
+	))
+
+	// Get the session cookie and its token.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+	if w.Code != http.StatusOK || len(token) == 0 {
+		t.Fatalf("GET got status %d and token %q", w.Code, token)
+	}
+	cookies := w.Result().Cookies()
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		form   string
+		bearer string
+		want   int
+	}{
+		{"safe method without token", "GET", "", "", "", http.StatusOK},
+		{"token in the header", "POST", token, "", "", http.StatusOK},
+		{"token in the form", "POST", "", token, "", http.StatusOK},
+		{"missing token", "POST", "", "", "", http.StatusForbidden},
+		{"wrong token", "DELETE", "wrong", "", "", http.StatusForbidden},
+		{"authenticated bearer token", "POST", "", "", "valid", http.StatusOK},
+		{"bearer token that fell back to the session", "POST", "", "", "invalid", http.StatusForbidden},
+		{"bearer token that fell back to the session with a token", "POST", token, "", "invalid", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/orders", strings.NewReader(url.Values{CSRFField: {tt.form}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for _, cookie := range cookies {
+				r.AddCookie(cookie)
+			}
+			if len(tt.header) > 0 {
+				r.Header.Set(CSRFHeader, tt.header)
+			}
+			if len(tt.bearer) > 0 {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package kit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Error is an error with the HTTP status and the message shown to the
+// client. The cause is only logged, it is never shown to the client.
+type Error struct {
+	Status  int    `json:"status"`
+	Message string `json:"error"`
+	// Fields holds the messages of invalid fields for validation errors.
+	Fields map[string][]string `json:"fields,omitempty"`
+	// RequestID lets support find the logs of the request.
+	RequestID string `json:"request_id,omitempty"`
+	Err       error  `json:"-"`
+}
+
+// NewError returns an error with the given status and public message,
+// caused by err, which may be nil. An empty message defaults to the
+// status text.
+func NewError(status int, message string, err error) *Error {
+	if len(message) == 0 {
+		message = http.StatusText(status)
+	}
+	return &Error{
+		Status:  status,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// NotFoundError returns a 404 error with the given public message.
+func NotFoundError(message string) *Error {
+	return NewError(http.StatusNotFound, message, nil)
+}
+
+// ForbiddenError returns a 403 error with the given public message.
+func ForbiddenError(message string) *Error {
+	return NewError(http.StatusForbidden, message, nil)
+}
+
+// ValidationError returns a 422 error with the messages of the invalid
+// fields, like the errors returned by validate.Request.
+func ValidationError(fields map[string][]string) *Error {
+	err := NewError(http.StatusUnprocessableEntity, "The submitted data is invalid", nil)
+	err.Fields = fields
+	return err
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsClientError returns true for 4xx errors, which are caused by the
+// request and not by the server.
+func (e *Error) IsClientError() bool {
+	return e.Status >= 400 && e.Status < 500
+}
+
+type errorMapping struct {
+	target  error
+	status  int
+	message string
+}
+
+var errorMappings []errorMapping
+
+// MapError maps errors matching target, as reported by errors.Is, to the
+// given status and public message. It lets libraries' errors like
+// gorm.ErrRecordNotFound become 404 responses without wrapping them in
+// every handler.
+func MapError(target error, status int, message string) {
+	errorMappings = append(errorMappings, errorMapping{
+		target:  target,
+		status:  status,
+		message: message,
+	})
+}
+
+// AsError converts any error returned by a handler into an *Error. Errors
+// that are or wrap an *Error keep it and mapped errors get their status.
+// Everything else is an internal server error.
+func AsError(err error) *Error {
+	var kitErr *Error
+	if errors.As(err, &kitErr) {
+		return kitErr
+	}
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.target) {
+			return NewError(mapping.status, mapping.message, err)
+		}
+	}
+	return NewError(http.StatusInternalServerError, "An unexpected error occurred", err)
+}
+
+// AsError converts the error like AsError, for handlers where kit names
+// the *Kit.
+func (kit *Kit) AsError(err error) *Error {
+	return AsError(err)
+}
+
+// URLParamID returns the URL parameter with the given name as an ID. An
+// invalid ID is a bad request.
+func (kit *Kit) URLParamID(name string) (uint, error) {
+	return parseID(chi.URLParam(kit.Request, name))
+}
+
+// FormID returns the form value with the given name as an ID. An invalid
+// ID is a bad request.
+func (kit *Kit) FormID(name string) (uint, error) {
+	return parseID(kit.FormValue(name))
+}
+
+func parseID(s string) (uint, error) {
+	id, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return 0, NewError(http.StatusBadRequest, "The request contains an invalid ID", err)
+	}
+	return uint(id), nil
+}
+
+// Error returns an error with the given status and public message from a
+// handler, caused by err, which may be nil.
+//
+//	return kit.Error(http.StatusNotFound, "order not found", err)
+func (kit *Kit) Error(status int, message string, err error) error {
+	return NewError(status, message, err)
+}
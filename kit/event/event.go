@@ -0,0 +1,196 @@
+// Package event is a simple in-process event stream. It started as a copy
+// of superkit's event package and keeps its API, but keeps track of the
+// running handlers so Shutdown can wait for them.
+package event
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HandlerFunc is the function being called when receiving an event.
+type HandlerFunc func(context.Context, any)
+
+// Emit and event to the given topic
+func Emit(topic string, event any) {
+	stream.emit(topic, event)
+}
+
+// Subscribe a HandlerFunc to the given topic.
+// A Subscription is being returned that can be used
+// to unsubscribe from the topic.
+func Subscribe(topic string, h HandlerFunc) Subscription {
+	return stream.subscribe(topic, h)
+}
+
+// Unsubscribe unsubribes the given Subscription from its topic.
+func Unsubscribe(sub Subscription) {
+	stream.unsubscribe(sub)
+}
+
+// Stop stops the event stream, cleaning up its resources. Events emitted
+// afterwards are dropped.
+func Stop() {
+	stream.stop()
+}
+
+// Shutdown stops the event stream after dispatching the events already
+// emitted and waits for all running handlers to return, or for ctx to be
+// done.
+func Shutdown(ctx context.Context) error {
+	stream.stop()
+	done := make(chan struct{})
+	go func() {
+		stream.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Len returns the number of emitted events waiting to be dispatched.
+func Len() int {
+	return len(stream.eventch)
+}
+
+var stream *eventStream
+
+type event struct {
+	topic   string
+	message any
+}
+
+// Subscription represents a handler subscribed to a specific topic.
+type Subscription struct {
+	Topic     string
+	CreatedAt int64
+	Fn        HandlerFunc
+}
+
+type eventStream struct {
+	mu       sync.RWMutex
+	subs     map[string][]Subscription
+	eventch  chan event
+	quitch   chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// closeMu guards closed. It is separate from mu, so emitters waiting
+	// for room in eventch don't keep start from dispatching.
+	closeMu  sync.RWMutex
+	closed   bool
+	handlers sync.WaitGroup
+}
+
+func newStream() *eventStream {
+	e := &eventStream{
+		subs:    make(map[string][]Subscription),
+		eventch: make(chan event, 128),
+		quitch:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.start()
+	return e
+}
+
+func (e *eventStream) start() {
+	defer close(e.stopped)
+	for {
+		select {
+		case <-e.quitch:
+			// Dispatch what was emitted before the stream was stopped.
+			for {
+				select {
+				case evt := <-e.eventch:
+					e.dispatch(evt)
+				default:
+					return
+				}
+			}
+		case evt := <-e.eventch:
+			e.dispatch(evt)
+		}
+	}
+}
+
+func (e *eventStream) dispatch(evt event) {
+	ctx := context.Background()
+	e.mu.RLock()
+	handlers := slices.Clone(e.subs[evt.topic])
+	e.mu.RUnlock()
+	for _, sub := range handlers {
+		e.handlers.Add(1)
+		go func() {
+			defer e.handlers.Done()
+			sub.Fn(ctx, evt.message)
+		}()
+	}
+}
+
+func (e *eventStream) stop() {
+	e.stopOnce.Do(func() {
+		e.closeMu.Lock()
+		e.closed = true
+		e.closeMu.Unlock()
+		close(e.quitch)
+	})
+	<-e.stopped
+}
+
+func (e *eventStream) emit(topic string, v any) {
+	// Holding the read lock keeps stop from closing the stream while the
+	// event is queued.
+	e.closeMu.RLock()
+	defer e.closeMu.RUnlock()
+	if e.closed {
+		slog.Warn("event dropped, the event stream is stopped", "topic", topic)
+		return
+	}
+	e.eventch <- event{
+		topic:   topic,
+		message: v,
+	}
+}
+
+func (e *eventStream) subscribe(topic string, h HandlerFunc) Subscription {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	sub := Subscription{
+		CreatedAt: time.Now().UnixNano(),
+		Topic:     topic,
+		Fn:        h,
+	}
+
+	if _, ok := e.subs[topic]; !ok {
+		e.subs[topic] = []Subscription{}
+	}
+
+	e.subs[topic] = append(e.subs[topic], sub)
+
+	return sub
+}
+
+func (e *eventStream) unsubscribe(sub Subscription) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.subs[sub.Topic]; ok {
+		e.subs[sub.Topic] = slices.DeleteFunc(e.subs[sub.Topic], func(e Subscription) bool {
+			return sub.CreatedAt == e.CreatedAt
+		})
+	}
+	if len(e.subs[sub.Topic]) == 0 {
+		delete(e.subs, sub.Topic)
+	}
+}
+
+func init() {
+	stream = newStream()
+}
@@ -0,0 +1,143 @@
+package kit
+
+import (
+	"context"
+	"encoding/gob"
+	"net/http"
+	"strings"
+)
+
+type FlashKind string
+
+const (
+	FlashKindSuccess FlashKind = "success"
+	FlashKindInfo    FlashKind = "info"
+	FlashKindError   FlashKind = "error"
+
+	flashSessionName = "flash"
+	flashMessagesKey = "messages"
+)
+
+// Flash is a message shown once on the next page the user sees, even if
+// the handler that added it redirects.
+type Flash struct {
+	Kind    FlashKind
+	Message string
+}
+
+type FlashKey struct{}
+
+// flashState holds the flash messages of a request: the ones left by
+// previous requests and the ones added by the current handler.
+type flashState struct {
+	messages []Flash
+}
+
+func init() {
+	// Session values are gob encoded into the cookie.
+	gob.Register([]Flash{})
+}
+
+// AddFlash stores a flash message in the session. It is shown on the
+// next full page, which is the current one if the handler renders a page
+// instead of redirecting.
+func (kit *Kit) AddFlash(kind FlashKind, msg string) error {
+	flash := Flash{Kind: kind, Message: msg}
+	if state, ok := kit.Request.Context().Value(FlashKey{}).(*flashState); ok {
+		state.messages = append(state.messages, flash)
+	}
+	sess := kit.GetSession(flashSessionName)
+	messages, _ := sess.Values[flashMessagesKey].([]Flash)
+	sess.Values[flashMessagesKey] = append(messages, flash)
+	return sess.Save(kit.Request, kit.Response)
+}
+
+// FlashSuccess adds a success flash message.
+func (kit *Kit) FlashSuccess(msg string) error {
+	return kit.AddFlash(FlashKindSuccess, msg)
+}
+
+// FlashInfo adds an informational flash message.
+func (kit *Kit) FlashInfo(msg string) error {
+	return kit.AddFlash(FlashKindInfo, msg)
+}
+
+// FlashError adds an error flash message.
+func (kit *Kit) FlashError(msg string) error {
+	return kit.AddFlash(FlashKindError, msg)
+}
+
+// Flashes returns the flash messages shown with the current response.
+func Flashes(ctx context.Context) []Flash {
+	state, ok := ctx.Value(FlashKey{}).(*flashState)
+	if !ok {
+		return nil
+	}
+	return state.messages
+}
+
+// WithFlash makes the flash messages in the session available to views.
+// They are removed from the session once a full HTML page is sent, so
+// they survive redirects, HX-Redirect responses and htmx requests that
+// only swap part of the page.
+func WithFlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kit := &Kit{
+			Response: w,
+			Request:  r,
+		}
+		messages, _ := kit.GetSession(flashSessionName).Values[flashMessagesKey].([]Flash)
+		state := &flashState{messages: messages}
+		r = r.WithContext(context.WithValue(r.Context(), FlashKey{}, state))
+		next.ServeHTTP(&flashWriter{ResponseWriter: w, request: r, state: state}, r)
+	})
+}
+
+// flashWriter clears the flash messages right before the headers of a
+// response that shows them are written.
+type flashWriter struct {
+	http.ResponseWriter
+	request     *http.Request
+	state       *flashState
+	wroteHeader bool
+}
+
+func (w *flashWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if len(w.state.messages) > 0 && w.showsPage(status) {
+			sess, _ := store.Get(w.request, flashSessionName)
+			delete(sess.Values, flashMessagesKey)
+			sess.Save(w.request, w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *flashWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *flashWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// showsPage returns true if the response is a full HTML page, which is
+// where the layout renders the flash messages.
+func (w *flashWriter) showsPage(status int) bool {
+	if status < 200 || status >= 300 {
+		return false
+	}
+	header := w.Header()
+	if len(header.Get("HX-Redirect")) > 0 || len(header.Get("HX-Location")) > 0 {
+		return false
+	}
+	if contentType := header.Get("Content-Type"); len(contentType) > 0 && !strings.HasPrefix(contentType, MIMEHTML) {
+		return false
+	}
+	kit := &Kit{Request: w.request}
+	return !kit.IsFragment()
+}
@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/a-h/templ"
 	"github.com/gorilla/sessions"
@@ -16,6 +17,10 @@ import (
 
 var store *sessions.CookieStore
 
+// env is the environment set with Configure. Until then it is read from
+// SUPERKIT_ENV.
+var env string
+
 type HandlerFunc func(kit *Kit) error
 
 type ErrorHandlerFunc func(kit *Kit, err error)
@@ -25,18 +30,40 @@ type AuthKey struct{}
 type Auth interface {
 	Check() bool
 	HasRole(role string) bool
+	Can(permission string) bool
 }
 
 var (
 	errorHandler = func(kit *Kit, err error) {
 		kit.Text(http.StatusInternalServerError, err.Error())
 	}
+	forbiddenHandler = func(kit *Kit) error {
+		return kit.Text(http.StatusForbidden, "Forbidden")
+	}
 )
 
+// ScopedAuth is implemented by an Auth that only holds some of the
+// permissions of its user, like an API token. Requests with a scoped Auth
+// are denied by default: they only reach the handlers of routes that
+// require a permission with WithPermission, and only if the Auth has it.
+type ScopedAuth interface {
+	Auth
+	Scoped() bool
+}
+
+// scopeKey holds the *scopeGrant of requests with a scoped Auth.
+type scopeKey struct{}
+
+// scopeGrant records if a route granted the scoped Auth of the request.
+type scopeGrant struct {
+	granted bool
+}
+
 type DefaultAuth struct{}
 
-func (DefaultAuth) Check() bool              { return false }
-func (DefaultAuth) HasRole(role string) bool { return false }
+func (DefaultAuth) Check() bool                { return false }
+func (DefaultAuth) HasRole(role string) bool   { return false }
+func (DefaultAuth) Can(permission string) bool { return false }
 
 type Kit struct {
 	Response http.ResponseWriter
@@ -45,6 +72,10 @@ type Kit struct {
 
 func UseErrorHandler(h ErrorHandlerFunc) { errorHandler = h }
 
+// UseForbiddenHandler sets the handler that renders the response when
+// WithRole or WithPermission rejects a request.
+func UseForbiddenHandler(h HandlerFunc) { forbiddenHandler = h }
+
 func (kit *Kit) Auth() Auth {
 	value, ok := kit.Request.Context().Value(AuthKey{}).(Auth)
 	if !ok {
@@ -54,7 +85,9 @@ func (kit *Kit) Auth() Auth {
 	return value
 }
 
-func WithRole(role string) func(http.Handler) http.Handler {
+// WithRole only lets the request through if the authenticated user has at
+// least one of the given roles. It must be used after WithAuthentication.
+func WithRole(roles ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			kit := &Kit{
@@ -62,15 +95,45 @@ func WithRole(role string) func(http.Handler) http.Handler {
 				Request:  r,
 			}
 			auth := kit.Auth()
-			if !auth.HasRole(role) {
-				kit.Text(http.StatusForbidden, "Forbidden")
+			for _, role := range roles {
+				if auth.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			kit.Forbidden()
+		})
+	}
+}
+
+// WithPermission only lets the request through if the authenticated user
+// has been granted the given permission. It must be used after WithAuthentication.
+func WithPermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kit := &Kit{
+				Response: w,
+				Request:  r,
+			}
+			if !kit.Auth().Can(permission) {
+				kit.Forbidden()
 				return
 			}
+			if grant, ok := r.Context().Value(scopeKey{}).(*scopeGrant); ok {
+				grant.granted = true
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// Forbidden responds with the handler set with UseForbiddenHandler.
+func (kit *Kit) Forbidden() {
+	if err := forbiddenHandler(kit); err != nil {
+		errorHandler(kit, err)
+	}
+}
+
 // GetSession return a session by its name. GetSession always
 // returns a session even if it does not exist.
 func (kit *Kit) GetSession(name string) *sessions.Session {
@@ -94,21 +157,25 @@ func (kit *Kit) FormValue(name string) string {
 }
 
 func (kit *Kit) JSON(status int, v any) error {
-	kit.Response.WriteHeader(status)
 	kit.Response.Header().Set("Content-Type", "application/json")
+	kit.Response.WriteHeader(status)
 	return json.NewEncoder(kit.Response).Encode(v)
 }
 
 func (kit *Kit) Text(status int, msg string) error {
-	kit.Response.WriteHeader(status)
 	kit.Response.Header().Set("Content-Type", "text/plain")
+	kit.Response.WriteHeader(status)
 	_, err := kit.Response.Write([]byte(msg))
 	return err
 }
 
+// Bytes writes b with the Content-Type already set on the response, or
+// the type detected from b.
 func (kit *Kit) Bytes(status int, b []byte) error {
+	if len(kit.Response.Header().Get("Content-Type")) == 0 {
+		kit.Response.Header().Set("Content-Type", http.DetectContentType(b))
+	}
 	kit.Response.WriteHeader(status)
-	kit.Response.Header().Set("Content-Type", "text/plain")
 	_, err := kit.Response.Write(b)
 	return err
 }
@@ -127,6 +194,18 @@ func Handler(h HandlerFunc) http.HandlerFunc {
 			Response: w,
 			Request:  r,
 		}
+		// A bearer token only replaces the CSRF token if the request was
+		// authenticated with it.
+		if check, ok := r.Context().Value(csrfDeferredKey{}).(*csrfCheck); ok && !check.exempt {
+			kit.csrfFailure()
+			return
+		}
+		// Scoped requests only reach the handler if a permission of the
+		// route granted them.
+		if grant, ok := r.Context().Value(scopeKey{}).(*scopeGrant); ok && !grant.granted {
+			kit.Forbidden()
+			return
+		}
 		if err := h(kit); err != nil {
 			if errorHandler != nil {
 				errorHandler(kit, err)
@@ -155,15 +234,38 @@ func WithAuthentication(config AuthenticationConfig, strict bool) func(http.Hand
 				return
 			}
 			if strict && !auth.Check() && r.URL.Path != config.RedirectURL {
+				// API clients can't follow a redirect to the login page.
+				if _, ok := BearerToken(r); ok {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
 				kit.Redirect(http.StatusSeeOther, config.RedirectURL)
 				return
 			}
 			ctx := context.WithValue(r.Context(), AuthKey{}, auth)
+			scoped, ok := auth.(ScopedAuth)
+			isScoped := ok && scoped.Scoped()
+			if isScoped {
+				ctx = context.WithValue(ctx, scopeKey{}, &scopeGrant{})
+			}
+			if check, ok := r.Context().Value(csrfDeferredKey{}).(*csrfCheck); ok {
+				check.exempt = isScoped
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || len(token) == 0 {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
 func Getenv(name string, def string) string {
 	env := os.Getenv(name)
 	if len(env) == 0 {
@@ -173,14 +275,17 @@ func Getenv(name string, def string) string {
 }
 
 func IsDevelopment() bool {
-	return os.Getenv("SUPERKIT_ENV") == "development"
+	return Env() == "development"
 }
 
 func IsProduction() bool {
-	return os.Getenv("SUPERKIT_ENV") == "production"
+	return Env() == "production"
 }
 
 func Env() string {
+	if len(env) > 0 {
+		return env
+	}
 	return os.Getenv("SUPERKIT_ENV")
 }
 
@@ -200,3 +305,15 @@ func Setup() {
 	}
 	store = sessions.NewCookieStore([]byte(appSecret))
 }
+
+// Configure sets the environment and creates the session store with the
+// app secret. It replaces Setup for applications that load and validate
+// their configuration themselves.
+func Configure(appEnv, appSecret string) error {
+	if len(appSecret) < 32 {
+		return fmt.Errorf("the app secret must be at least 32 characters long")
+	}
+	env = appEnv
+	store = sessions.NewCookieStore([]byte(appSecret))
+	return nil
+}
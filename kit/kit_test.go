@@ -0,0 +1,86 @@
+package kit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testAuth is an Auth with a single role and permission, scoped like an
+// API token if scoped is set.
+type testAuth struct {
+	role       string
+	permission string
+	scoped     bool
+}
+
+func (auth testAuth) Check() bool                { return true }
+func (auth testAuth) HasRole(role string) bool   { return auth.role == role }
+func (auth testAuth) Can(permission string) bool { return auth.permission == permission }
+func (auth testAuth) Scoped() bool               { return auth.scoped }
+
+func TestScopedAuth(t *testing.T) {
+	ok := Handler(func(kit *Kit) error {
+		return kit.Text(http.StatusOK, "ok")
+	})
+	session := testAuth{role: "staff", permission: "meals.manage"}
+	token := testAuth{role: "staff", permission: "meals.manage", scoped: true}
+
+	tests := []struct {
+		name       string
+		auth       Auth
+		middleware []func(http.Handler) http.Handler
+		want       int
+	}{
+		{"session on an open route", session, nil, http.StatusOK},
+		{"token on an open route", token, nil, http.StatusForbidden},
+		{"session with the permission", session, []func(http.Handler) http.Handler{WithPermission("meals.manage")}, http.StatusOK},
+		{"token with the scope", token, []func(http.Handler) http.Handler{WithPermission("meals.manage")}, http.StatusOK},
+		{"token without the scope", token, []func(http.Handler) http.Handler{WithPermission("users.manage")}, http.StatusForbidden},
+		{"session with the role", session, []func(http.Handler) http.Handler{WithRole("staff")}, http.StatusOK},
+		{"token on a role route", testAuth{permission: "meals.manage", scoped: true}, []func(http.Handler) http.Handler{WithRole("staff")}, http.StatusForbidden},
+		{"token with the scope after a role", token, []func(http.Handler) http.Handler{WithRole("staff"), WithPermission("meals.manage")}, http.StatusOK},
+		{"unscoped ScopedAuth on an open route", testAuth{scoped: false}, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handler http.Handler = ok
+			for i := len(tt.middleware) - 1; i >= 0; i-- {
+				handler = tt.middleware[i](handler)
+			}
+			config := AuthenticationConfig{
+				AuthFunc: func(*Kit) (Auth, error) { return tt.auth, nil },
+			}
+			handler = WithAuthentication(config, false)(handler)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"bearer abc ", "abc", true},
+		{"Basic abc", "", false},
+		{"Bearer", "", false},
+		{"Bearer ", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", tt.header)
+		token, ok := BearerToken(r)
+		if token != tt.token || ok != tt.ok {
+			t.Errorf("BearerToken(%q) = %q, %v, want %q, %v", tt.header, token, ok, tt.token, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package kit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLength limits the IDs accepted from clients and proxies,
+	// so they can't flood the logs.
+	maxRequestIDLength = 128
+)
+
+type (
+	RequestIDKey struct{}
+	LoggerKey    struct{}
+)
+
+// NewLogger returns a logger writing JSON, for log collectors, or text,
+// for humans. An empty format picks JSON in production and text
+// everywhere else.
+func NewLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	if len(format) == 0 {
+		format = "text"
+		if IsProduction() {
+			format = "json"
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Logger returns the logger of the request, which adds its request ID to
+// every record, or the default logger outside of requests.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(LoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Logger returns the logger of the request.
+//
+//	kit.Logger().Info("order placed", "order", order.ID)
+func (kit *Kit) Logger() *slog.Logger {
+	return Logger(kit.Request.Context())
+}
+
+// RequestID returns the ID of the request set by WithRequestID.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey{}).(string)
+	return id
+}
+
+// RequestID returns the ID of the request set by WithRequestID.
+func (kit *Kit) RequestID() string {
+	return RequestID(kit.Request.Context())
+}
+
+// WithRequestID assigns every request an ID, or keeps the one set by a
+// proxy in the X-Request-ID header, and sends it back in the same header.
+// The request's logger adds the ID to all its records.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), RequestIDKey{}, id)
+		ctx = context.WithValue(ctx, LoggerKey{}, slog.Default().With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithRequestLogger logs every request once it is done, with its status,
+// size and duration. It must be used after WithRequestID.
+func WithRequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+				"remote", ClientIP(r),
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && len(rctx.RoutePattern()) > 0 {
+				attrs = append(attrs, "route", rctx.RoutePattern())
+			}
+			Logger(r.Context()).Log(r.Context(), level, "request", attrs...)
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// WithRecover turns panics in handlers into internal server errors shown
+// by the error handler, so the page has the request ID like any other
+// error. The stack is logged.
+func WithRecover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// The server aborts the response without logging.
+				panic(rvr)
+			}
+			Logger(r.Context()).Error("panic", "panic", rvr, "stack", string(debug.Stack()))
+			kit := &Kit{
+				Response: w,
+				Request:  r,
+			}
+			errorHandler(kit, fmt.Errorf("panic: %v", rvr))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(r rune) bool {
+		return r <= ' ' || r > '~'
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
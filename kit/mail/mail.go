@@ -0,0 +1,250 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gothstack/kit"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TransportSMTP    = "smtp"
+	TransportMaildir = "maildir"
+	TransportMemory  = "memory"
+)
+
+// Message is an email with a plain text and an optional HTML body.
+type Message struct {
+	From    string
+	To      []string
+	ReplyTo string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Transport delivers messages.
+type Transport interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	mu        sync.RWMutex
+	transport Transport
+	config    *Config
+)
+
+// Use sets the transport used by Send. Without it the transport is
+// created on first use, from the Config set with Configure or else from
+// the environment.
+func Use(t Transport) {
+	mu.Lock()
+	defer mu.Unlock()
+	transport = t
+}
+
+// Send delivers the message with the configured transport. The From
+// address defaults to the configured one.
+func Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("mail: message has no recipients")
+	}
+	if len(msg.From) == 0 {
+		msg.From = from()
+	}
+	t, err := current()
+	if err != nil {
+		return err
+	}
+	return t.Send(ctx, msg)
+}
+
+func current() (Transport, error) {
+	mu.RLock()
+	t := transport
+	mu.RUnlock()
+	if t != nil {
+		return t, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if transport == nil {
+		var t Transport
+		var err error
+		if config != nil {
+			t, err = New(*config)
+		} else {
+			t, err = FromEnv()
+		}
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	}
+	return transport, nil
+}
+
+// Config holds the settings of the transport created by New.
+type Config struct {
+	// Transport is smtp, maildir or memory. It defaults to SMTP in
+	// production and to a maildir everywhere else.
+	Transport string
+	From      string
+	Outbox    string
+	Maildir   string
+	SMTP      SMTPConfig
+}
+
+// Configure sets the configuration used for the From address and the
+// transport that is created on the first Send.
+func Configure(cfg Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	config = &cfg
+}
+
+func from() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if config != nil && len(config.From) > 0 {
+		return config.From
+	}
+	return kit.Getenv("SUPERKIT_MAIL_FROM", "noreply@localhost")
+}
+
+// New creates the transport named by cfg.Transport. In development every
+// message is captured in the outbox instead of being delivered.
+func New(cfg Config) (Transport, error) {
+	if kit.IsDevelopment() {
+		return NewOutbox(cfg.Outbox)
+	}
+	name := cfg.Transport
+	if len(name) == 0 {
+		name = TransportMaildir
+		if kit.IsProduction() {
+			name = TransportSMTP
+		}
+	}
+	switch name {
+	case TransportSMTP:
+		return NewSMTPTransport(cfg.SMTP), nil
+	case TransportMaildir:
+		return NewMaildirTransport(cfg.Maildir)
+	case TransportMemory:
+		return NewMemoryTransport(), nil
+	default:
+		return nil, fmt.Errorf("mail: unknown transport %q", name)
+	}
+}
+
+// FromEnv creates the transport from the SUPERKIT_MAIL_* environment
+// variables.
+func FromEnv() (Transport, error) {
+	return New(Config{
+		Transport: kit.Getenv("SUPERKIT_MAIL_TRANSPORT", ""),
+		Outbox:    kit.Getenv("SUPERKIT_MAIL_OUTBOX", "tmp/outbox"),
+		Maildir:   kit.Getenv("SUPERKIT_MAIL_MAILDIR", "tmp/mail"),
+		SMTP: SMTPConfig{
+			Host:     kit.Getenv("SUPERKIT_MAIL_SMTP_HOST", "localhost"),
+			Port:     kit.Getenv("SUPERKIT_MAIL_SMTP_PORT", "587"),
+			Username: kit.Getenv("SUPERKIT_MAIL_SMTP_USERNAME", ""),
+			Password: kit.Getenv("SUPERKIT_MAIL_SMTP_PASSWORD", ""),
+			TLS:      kit.Getenv("SUPERKIT_MAIL_SMTP_TLS", "false") == "true",
+		},
+	})
+}
+
+// Bytes encodes the message as RFC 5322 with a multipart/alternative body
+// when it has both a plain text and an HTML version.
+func (msg Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, fmt.Errorf("mail: invalid from address: %w", err)
+	}
+	to := make([]string, len(msg.To))
+	for i, addr := range msg.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("mail: invalid recipient: %w", err)
+		}
+		to[i] = parsed.String()
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from.String())
+	header("To", strings.Join(to, ", "))
+	if len(msg.ReplyTo) > 0 {
+		header("Reply-To", msg.ReplyTo)
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	if len(msg.HTML) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// MaildirTransport writes every message as a file into a maildir, so it
+// can be read with any mail client that supports the format.
+type MaildirTransport struct {
+	dir   string
+	count atomic.Uint64
+}
+
+// NewMaildirTransport creates the tmp, new and cur folders in dir if they
+// don't exist yet.
+func NewMaildirTransport(dir string) (*MaildirTransport, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("mail: create maildir: %w", err)
+		}
+	}
+	return &MaildirTransport{dir: dir}, nil
+}
+
+func (t *MaildirTransport) Send(ctx context.Context, msg Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	// Files are written to tmp first and moved to new once complete, so
+	// readers never see a partial message.
+	name := fmt.Sprintf("%d.%d_%d.%s", time.Now().Unix(), os.Getpid(), t.count.Add(1), host)
+	tmp := filepath.Join(t.dir, "tmp", name)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.dir, "new", name))
+}
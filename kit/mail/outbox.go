@@ -0,0 +1,181 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrMessageNotFound is returned by Outbox.Get for unknown message IDs.
+var ErrMessageNotFound = errors.New("mail: message not found")
+
+// Outbox captures messages instead of delivering them, so they can be
+// read in the browser during development. Messages are kept in a maildir
+// and survive restarts of the server.
+type Outbox struct {
+	*MaildirTransport
+}
+
+// OutboxMessage is a captured message decoded for display.
+type OutboxMessage struct {
+	ID      string
+	Header  mail.Header
+	From    string
+	To      string
+	Subject string
+	Date    time.Time
+	Text    string
+	HTML    string
+}
+
+func NewOutbox(dir string) (*Outbox, error) {
+	t, err := NewMaildirTransport(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Outbox{MaildirTransport: t}, nil
+}
+
+// CurrentOutbox returns the outbox if it is the configured transport.
+func CurrentOutbox() (*Outbox, bool) {
+	t, err := current()
+	if err != nil {
+		return nil, false
+	}
+	outbox, ok := t.(*Outbox)
+	return outbox, ok
+}
+
+// List returns all captured messages, newest first.
+func (o *Outbox) List() ([]OutboxMessage, error) {
+	entries, err := os.ReadDir(filepath.Join(o.dir, "new"))
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]OutboxMessage, 0, len(entries))
+	// Entries are sorted by name, which starts with the time the message
+	// was sent, so walking them backwards keeps the newest first when
+	// the Date headers are equal.
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.IsDir() {
+			continue
+		}
+		msg, err := o.Get(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Date.After(messages[j].Date)
+	})
+	return messages, nil
+}
+
+// Get returns the captured message with the given ID.
+func (o *Outbox) Get(id string) (OutboxMessage, error) {
+	if id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return OutboxMessage{}, ErrMessageNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(o.dir, "new", id))
+	if errors.Is(err, os.ErrNotExist) {
+		return OutboxMessage{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	return parseMessage(id, data)
+}
+
+// Clear removes all captured messages.
+func (o *Outbox) Clear() error {
+	entries, err := os.ReadDir(filepath.Join(o.dir, "new"))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Remove(filepath.Join(o.dir, "new", entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseMessage(id string, data []byte) (OutboxMessage, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	var dec mime.WordDecoder
+	subject, err := dec.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		subject = m.Header.Get("Subject")
+	}
+	date, _ := m.Header.Date()
+	msg := OutboxMessage{
+		ID:      id,
+		Header:  m.Header,
+		From:    m.Header.Get("From"),
+		To:      m.Header.Get("To"),
+		Subject: subject,
+		Date:    date,
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		return msg, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := decodeBody(m.Body, m.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return msg, err
+		}
+		setBody(&msg, mediaType, body)
+		return msg, nil
+	}
+
+	// The multipart reader decodes quoted-printable parts by itself.
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return msg, nil
+		}
+		if err != nil {
+			return msg, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(part)
+		if err != nil {
+			return msg, err
+		}
+		setBody(&msg, partType, string(body))
+	}
+}
+
+func decodeBody(r io.Reader, encoding string) (string, error) {
+	if strings.EqualFold(encoding, "quoted-printable") {
+		r = quotedprintable.NewReader(r)
+	}
+	body, err := io.ReadAll(r)
+	return string(body), err
+}
+
+func setBody(msg *OutboxMessage, mediaType, body string) {
+	switch mediaType {
+	case "text/html":
+		msg.HTML = body
+	default:
+		msg.Text = body
+	}
+}
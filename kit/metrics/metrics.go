@@ -0,0 +1,308 @@
+// Package metrics collects counters, gauges and histograms and exposes
+// them in the Prometheus text format. It only implements what the
+// application needs, without the dependencies of the Prometheus client.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of the
+// request duration histogram.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+var (
+	mu      sync.Mutex
+	metrics = map[string]metric{}
+)
+
+func register(m metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := metrics[m.name()]; ok {
+		panic("metrics: duplicate metric " + m.name())
+	}
+	metrics[m.name()] = m
+}
+
+// Counter is a value that only goes up, partitioned by its labels.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels []string
+	value  float64
+}
+
+// NewCounter registers a counter with the given label names.
+//
+//	orders := metrics.NewCounter("orders_total", "Orders placed.", "status")
+//	orders.Inc("pending")
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{metricName: name, help: help, typ: "counter", labels: labels},
+		series: map[string]*counterSeries{},
+	}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds v, which must not be negative, to the series with the given
+// label values.
+func (c *Counter) Add(v float64, labels ...string) {
+	c.checkLabels(labels)
+	key := seriesKey(labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{labels: slices.Clone(labels)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w)
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		c.sample(w, "", s.labels, nil, s.value)
+	}
+}
+
+// Histogram counts observations, like request durations, in buckets.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// in increasing order, and label names.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	register(h)
+	return h
+}
+
+// Observe adds v to the series with the given label values.
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.checkLabels(labels)
+	key := seriesKey(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labels: slices.Clone(labels), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			h.sample(w, "_bucket", s.labels, []string{"le", formatFloat(bound)}, float64(s.counts[i]))
+		}
+		h.sample(w, "_bucket", s.labels, []string{"le", "+Inf"}, float64(s.count))
+		h.sample(w, "_sum", s.labels, nil, s.sum)
+		h.sample(w, "_count", s.labels, nil, float64(s.count))
+	}
+}
+
+// Func is a metric whose value is read when the metrics are collected,
+// like the size of a queue.
+type Func struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge, a value that goes up and down, read
+// from fn.
+func NewGaugeFunc(name, help string, fn func() float64) *Func {
+	f := &Func{desc: desc{metricName: name, help: help, typ: "gauge"}, fn: fn}
+	register(f)
+	return f
+}
+
+// NewCounterFunc registers a counter read from fn, for values that are
+// already counted elsewhere.
+func NewCounterFunc(name, help string, fn func() float64) *Func {
+	f := &Func{desc: desc{metricName: name, help: help, typ: "counter"}, fn: fn}
+	register(f)
+	return f
+}
+
+func (f *Func) write(w *bufio.Writer) {
+	f.header(w)
+	f.sample(w, "", nil, nil, f.fn())
+}
+
+var (
+	requestDuration = NewHistogram("http_request_duration_seconds",
+		"Duration of HTTP requests by route.", DefaultBuckets, "method", "route")
+	requestsTotal = NewCounter("http_requests_total",
+		"HTTP requests by route and status code.", "method", "route", "code")
+)
+
+// Middleware records the duration and status code of every request. The
+// route is the chi route pattern, like /orders/{id}, so the number of
+// series doesn't grow with the IDs in the URLs.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && len(rctx.RoutePattern()) > 0 {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			requestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+			requestsTotal.Inc(r.Method, route, strconv.Itoa(status))
+		}()
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// Handler serves all registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		all := make([]metric, 0, len(metrics))
+		for _, m := range metrics {
+			all = append(all, m)
+		}
+		mu.Unlock()
+		slices.SortFunc(all, func(a, b metric) int {
+			return strings.Compare(a.name(), b.name())
+		})
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		for _, m := range all {
+			m.write(bw)
+		}
+		bw.Flush()
+	})
+}
+
+// desc holds what all metrics have in common.
+type desc struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, help, d.metricName, d.typ)
+}
+
+// sample writes one line with the label values of the series and an extra
+// label pair, like the le label of histogram buckets.
+func (d *desc) sample(w *bufio.Writer, suffix string, values []string, extra []string, v float64) {
+	w.WriteString(d.metricName + suffix)
+	var pairs []string
+	for i, value := range values {
+		pairs = append(pairs, d.labels[i]+`="`+escapeLabel(value)+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func seriesKey(labels []string) string {
+	return strings.Join(labels, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,116 @@
+// Package money holds sums of money as whole cents, so prices add up
+// without the rounding errors of floats.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a sum of money in cents. It binds from form values like
+// "12.5" with validate.Bind and prints as "12.50". Note that the Min and
+// Max rules of validate compare cents.
+type Amount int64
+
+var errInvalidAmount = errors.New("invalid amount")
+
+// Parse parses a decimal amount with at most two decimal places, like
+// "12", "12.5" or "-0.99".
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	units, fraction, _ := strings.Cut(s, ".")
+	if len(units) == 0 && len(fraction) == 0 || len(fraction) > 2 || !digits(units) || !digits(fraction) {
+		return 0, errInvalidAmount
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+	if len(units) == 0 {
+		units = "0"
+	}
+	n, err := strconv.ParseInt(units+fraction, 10, 64)
+	if err != nil {
+		return 0, errInvalidAmount
+	}
+	if negative {
+		n = -n
+	}
+	return Amount(n), nil
+}
+
+// FromFloat rounds f to the nearest cent.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * 100))
+}
+
+// Mul returns the amount times n, like the total of n items.
+func (a Amount) Mul(n int) Amount {
+	return a * Amount(n)
+}
+
+// Float returns the amount in units, e.g. 12.5 for 1250 cents.
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// String formats the amount with two decimal places.
+func (a Amount) String() string {
+	sign := ""
+	n := int64(a)
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/100, n%100)
+}
+
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalText(text []byte) error {
+	amount, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// Value stores the amount in units, so existing REAL columns keep their
+// meaning.
+func (a Amount) Value() (driver.Value, error) {
+	return a.Float(), nil
+}
+
+// Scan reads amounts stored in units as numbers or text.
+func (a *Amount) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*a = 0
+	case float64:
+		*a = FromFloat(src)
+	case int64:
+		*a = Amount(src * 100)
+	case []byte:
+		return a.UnmarshalText(src)
+	case string:
+		return a.UnmarshalText([]byte(src))
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+	return nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
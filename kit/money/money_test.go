@@ -0,0 +1,113 @@
+package money
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Amount
+		ok   bool
+	}{
+		{"12", 1200, true},
+		{"12.5", 1250, true},
+		{"12.50", 1250, true},
+		{" 0.99 ", 99, true},
+		{"-0.99", -99, true},
+		{".5", 50, true},
+		{"5.", 500, true},
+		{"0", 0, true},
+		{"12.345", 0, false},
+		{"0.001", 0, false},
+		{"", 0, false},
+		{".", 0, false},
+		{"-", 0, false},
+		{"1,50", 0, false},
+		{"1e3", 0, false},
+		{"+1", 0, false},
+		{"--1", 0, false},
+		{"1.-5", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.s)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) = %v, %v, want %v, ok %v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want Amount
+	}{
+		{12.5, 1250},
+		{0.1 + 0.2, 30},
+		{1.005, 100},
+		{2.675, 268},
+		{-0.994, -99},
+		{-0.995, -100},
+	}
+	for _, tt := range tests {
+		if got := FromFloat(tt.f); got != tt.want {
+			t.Errorf("FromFloat(%v) = %d, want %d", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		a    Amount
+		want string
+	}{
+		{1250, "12.50"},
+		{5, "0.05"},
+		{0, "0.00"},
+		{-99, "-0.99"},
+		{-1250, "-12.50"},
+	}
+	for _, tt := range tests {
+		if got := tt.a.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestValueScan(t *testing.T) {
+	for _, a := range []Amount{0, 1, 99, 1250, -1250, 123456789} {
+		value, err := a.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Amount
+		if err := got.Scan(value); err != nil {
+			t.Fatal(err)
+		}
+		if got != a {
+			t.Errorf("Scan(Value()) of %d = %d", a, got)
+		}
+	}
+
+	tests := []struct {
+		src  any
+		want Amount
+		ok   bool
+	}{
+		{nil, 0, true},
+		{int64(12), 1200, true},
+		{12.5, 1250, true},
+		{[]byte("12.50"), 1250, true},
+		{"0.99", 99, true},
+		{"abc", 0, false},
+		{true, 0, false},
+	}
+	for _, tt := range tests {
+		a := Amount(7)
+		err := a.Scan(tt.src)
+		if (err == nil) != tt.ok || (tt.ok && a != tt.want) {
+			t.Errorf("Scan(%#v) = %d, %v, want %d, ok %v", tt.src, a, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package kit
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+const (
+	MIMEHTML = "text/html"
+	MIMEJSON = "application/json"
+)
+
+// Encoder writes v to w in the format of a media type.
+type Encoder func(w io.Writer, v any) error
+
+var (
+	encoders = map[string]Encoder{
+		MIMEJSON: func(w io.Writer, v any) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+	}
+	// offers are the media types Respond can produce, in order of
+	// preference when the client accepts several equally.
+	offers = []string{MIMEHTML, MIMEJSON}
+)
+
+// UseEncoder registers the encoder for a media type, so Respond can serve
+// data in that format. Registering a known media type replaces its
+// encoder.
+func UseEncoder(mediaType string, enc Encoder) {
+	if _, ok := encoders[mediaType]; !ok {
+		offers = append(offers, mediaType)
+	}
+	encoders[mediaType] = enc
+}
+
+// Respond writes data in the format the client asked for: the page for
+// browsers and htmx, or data encoded with the registered encoder that
+// best matches the Accept header. Data is also encoded when page is nil.
+func (kit *Kit) Respond(status int, data any, page templ.Component) error {
+	return kit.RespondFragment(status, data, page, page)
+}
+
+// RespondFragment works like Respond, but renders fragment instead of
+// page for htmx requests that swap part of the page.
+func (kit *Kit) RespondFragment(status int, data any, page, fragment templ.Component) error {
+	header := kit.Response.Header()
+	header.Add("Vary", "Accept")
+	header.Add("Vary", "HX-Request")
+
+	mediaType := kit.Negotiate()
+	if mediaType == MIMEHTML && page == nil {
+		mediaType = MIMEJSON
+	}
+	if mediaType != MIMEHTML {
+		header.Set("Content-Type", mediaType)
+		kit.Response.WriteHeader(status)
+		return encoders[mediaType](kit.Response, data)
+	}
+
+	component := page
+	if fragment != nil && kit.IsFragment() {
+		component = fragment
+	}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	kit.Response.WriteHeader(status)
+	return kit.Render(component)
+}
+
+// IsFragment returns true for htmx requests that swap part of a page.
+// Boosted links and forms replace the whole body and get the full page.
+func (kit *Kit) IsFragment() bool {
+	return len(kit.Request.Header.Get("HX-Request")) > 0 && len(kit.Request.Header.Get("HX-Boosted")) == 0
+}
+
+// Negotiate returns the media type Respond produces for the request. htmx
+// requests and requests without an Accept header always get HTML.
+func (kit *Kit) Negotiate() string {
+	accept := kit.Request.Header.Get("Accept")
+	if len(kit.Request.Header.Get("HX-Request")) > 0 || len(accept) == 0 {
+		return MIMEHTML
+	}
+
+	best, bestQ := MIMEHTML, 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality the Accept header gives the media
+// type, using the most specific matching range.
+func acceptQuality(accept, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch {
+		case accepted == mediaType:
+			s = 2
+		case strings.HasSuffix(accepted, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(accepted, "*")):
+			s = 1
+		case accepted == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity = s
+		q = 1
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return q
+}
@@ -0,0 +1,83 @@
+package kit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		proxies []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"10.0.0.1"}, []string{"10.0.0.1/32"}, false},
+		{[]string{" 10.0.0.0/8 ", "::1"}, []string{"10.0.0.0/8", "::1/128"}, false},
+		{[]string{"10.1.2.3/8"}, []string{"10.0.0.0/8"}, false},
+		{[]string{"::ffff:10.0.0.1"}, []string{"10.0.0.1/32"}, false},
+		{[]string{"proxy.local"}, nil, true},
+		{[]string{"10.0.0.0/33"}, nil, true},
+	}
+	for _, tt := range tests {
+		prefixes, err := ParseTrustedProxies(tt.proxies)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTrustedProxies(%q) error = %v, want error %v", tt.proxies, err, tt.wantErr)
+			continue
+		}
+		if len(prefixes) != len(tt.want) {
+			t.Errorf("ParseTrustedProxies(%q) = %v, want %v", tt.proxies, prefixes, tt.want)
+			continue
+		}
+		for i, prefix := range prefixes {
+			if prefix.String() != tt.want[i] {
+				t.Errorf("ParseTrustedProxies(%q) = %v, want %v", tt.proxies, prefixes, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	if err := UseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { trustedProxies = nil })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:1234", nil, "", "203.0.113.7"},
+		{"direct client spoofing X-Forwarded-For", "203.0.113.7:1234", []string{"198.51.100.1"}, "", "203.0.113.7"},
+		{"direct client spoofing X-Real-IP", "203.0.113.7:1234", nil, "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"trusted proxy without headers", "10.0.0.2:1234", nil, "", "10.0.0.2"},
+		{"chain of trusted proxies", "10.0.0.2:1234", []string{"198.51.100.1, 192.0.2.1, 10.0.0.3"}, "", "198.51.100.1"},
+		{"spoofed entry left of the client", "10.0.0.2:1234", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"multiple headers", "10.0.0.2:1234", []string{"1.2.3.4", "198.51.100.1"}, "", "198.51.100.1"},
+		{"only trusted proxies forwarded", "10.0.0.2:1234", []string{"10.0.0.4, 10.0.0.3"}, "", "10.0.0.4"},
+		{"invalid entry stops the walk", "10.0.0.2:1234", []string{"198.51.100.1, garbage"}, "198.51.100.2", "198.51.100.2"},
+		{"X-Real-IP from a trusted proxy", "192.0.2.1:1234", nil, "198.51.100.1", "198.51.100.1"},
+		{"invalid X-Real-IP", "192.0.2.1:1234", nil, "garbage", "192.0.2.1"},
+		{"IPv4 mapped IPv6", "[::ffff:10.0.0.2]:1234", []string{"::ffff:198.51.100.1"}, "", "198.51.100.1"},
+		{"IPv6 client", "[2001:db8::1]:1234", nil, "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			if len(tt.realIP) > 0 {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
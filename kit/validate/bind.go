@@ -0,0 +1,211 @@
+package validate
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxMultipartMemory = 32 << 20
+
+// timeLayouts are tried in order for time.Time fields without a layout
+// tag. They cover the values of date, time and datetime-local inputs.
+var timeLayouts = []string{
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02",
+	"15:04",
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Bind fills the exported fields of the struct data points to from the
+// URL parameters, the query string and the form body of the request.
+//
+// A field is bound from the URL parameter named by its param tag, or else
+// from the form value named by its form tag. Fields without either tag are
+// left alone, so values set by handlers can't be overwritten by clients.
+// Supported are strings, bools, all int, uint and float kinds, time.Time,
+// types implementing encoding.TextUnmarshaler like decimals, pointers to
+// them and slices of them. Times are parsed in the local time zone using
+// the layout tag, or the layouts of HTML date and time inputs.
+//
+// Empty values leave the field untouched. Values that can't be parsed are
+// returned as errors keyed like validation errors.
+func Bind(r *http.Request, data any) Errors {
+	errs := Errors{}
+	val := reflect.ValueOf(data)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		errs.Add("_error", "bind target must be a pointer to a struct")
+		return errs
+	}
+
+	if err := parseRequest(r); err != nil {
+		errs.Add("_error", err.Error())
+		return errs
+	}
+
+	val = val.Elem()
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, param := field.Tag.Get("form"), field.Tag.Get("param")
+		if !field.IsExported() || name == "-" || len(name)+len(param) == 0 {
+			continue
+		}
+		name, _, _ = strings.Cut(name, ",")
+
+		var values []string
+		if len(param) > 0 {
+			if value := chi.URLParam(r, param); len(value) > 0 {
+				values = []string{value}
+			}
+		}
+		if len(values) == 0 && len(name) > 0 {
+			values = nonEmpty(r.Form[name])
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setField(val.Field(i), field, values); err != nil {
+			errs.Add(errorKey(field), err.Error())
+		}
+	}
+	return errs
+}
+
+func parseRequest(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+			return fmt.Errorf("failed to parse form: %v", err)
+		}
+		return nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("failed to parse form: %v", err)
+	}
+	return nil
+}
+
+func nonEmpty(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if len(strings.TrimSpace(value)) > 0 {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func setField(fieldVal reflect.Value, field reflect.StructField, values []string) error {
+	if fieldVal.Kind() == reflect.Slice && !implementsTextUnmarshaler(fieldVal.Type()) {
+		slice := reflect.MakeSlice(fieldVal.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), field, value); err != nil {
+				return err
+			}
+		}
+		fieldVal.Set(slice)
+		return nil
+	}
+	return setValue(fieldVal, field, values[len(values)-1])
+}
+
+func setValue(fieldVal reflect.Value, field reflect.StructField, value string) error {
+	if fieldVal.Kind() == reflect.Ptr {
+		ptr := reflect.New(fieldVal.Type().Elem())
+		if err := setValue(ptr.Elem(), field, value); err != nil {
+			return err
+		}
+		fieldVal.Set(ptr)
+		return nil
+	}
+
+	if fieldVal.Kind() != reflect.String {
+		value = strings.TrimSpace(value)
+	}
+	if fieldVal.Type() == timeType {
+		t, err := parseTime(field.Tag.Get("layout"), value)
+		if err != nil {
+			return err
+		}
+		fieldVal.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if implementsTextUnmarshaler(fieldVal.Type()) {
+		if err := fieldVal.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return errors.New("is not a valid value")
+		}
+		return nil
+	}
+
+	switch fieldVal.Kind() {
+	case reflect.String:
+		fieldVal.SetString(value)
+	case reflect.Bool:
+		// There are cases where frontend libraries use "on" as the bool value
+		// think about toggles. Hence, let's try this first.
+		switch value {
+		case "on":
+			fieldVal.SetBool(true)
+		case "off":
+			fieldVal.SetBool(false)
+		default:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.New("is not a valid boolean")
+			}
+			fieldVal.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fieldVal.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid whole number")
+		}
+		fieldVal.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fieldVal.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid positive whole number")
+		}
+		fieldVal.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fieldVal.Type().Bits())
+		if err != nil {
+			return errors.New("is not a valid number")
+		}
+		fieldVal.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported kind %s", fieldVal.Kind())
+	}
+	return nil
+}
+
+func implementsTextUnmarshaler(typ reflect.Type) bool {
+	return reflect.PointerTo(typ).Implements(textUnmarshalerType)
+}
+
+func parseTime(layout, value string) (time.Time, error) {
+	layouts := timeLayouts
+	if len(layout) > 0 {
+		layouts = []string{layout}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("is not a valid date or time")
+}
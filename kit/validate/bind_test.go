@@ -0,0 +1,141 @@
+package validate
+
+import (
+	"context"
+	"gothstack/kit/money"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type bindForm struct {
+	ID       uint         `param:"id"`
+	Name     string       `form:"name"`
+	Active   bool         `form:"active"`
+	Count    int8         `form:"count"`
+	Total    uint16       `form:"total"`
+	Ratio    float64      `form:"ratio"`
+	Price    money.Amount `form:"price"`
+	Date     time.Time    `form:"date"`
+	Day      time.Time    `form:"day" layout:"02.01.2006"`
+	Quantity *int         `form:"quantity"`
+	Tags     []string     `form:"tags"`
+	IDs      []uint       `form:"ids"`
+	Role     string
+	secret   string `form:"secret"`
+}
+
+// bindRequest posts the form to a route with the given id URL parameter.
+func bindRequest(form url.Values, id string) *http.Request {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	routeCtx := chi.NewRouteContext()
+	if len(id) > 0 {
+		routeCtx.URLParams.Add("id", id)
+	}
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestBind(t *testing.T) {
+	r := bindRequest(url.Values{
+		"name":     {" Jane "},
+		"active":   {"on"},
+		"count":    {"-12"},
+		"total":    {" 300 "},
+		"ratio":    {"0.25"},
+		"price":    {"12.5"},
+		"date":     {"2025-03-01T18:30"},
+		"day":      {"02.03.2025"},
+		"quantity": {"3"},
+		"tags":     {"a", "", "b"},
+		"ids":      {"1", "2"},
+		"Role":     {"admin"},
+		"secret":   {"s"},
+	}, "42")
+	var form bindForm
+	if errs := Bind(r, &form); errs.Any() {
+		t.Fatalf("Bind returned errors %v", errs)
+	}
+	quantity := 3
+	want := bindForm{
+		ID:       42,
+		Name:     " Jane ",
+		Active:   true,
+		Count:    -12,
+		Total:    300,
+		Ratio:    0.25,
+		Price:    1250,
+		Date:     time.Date(2025, 3, 1, 18, 30, 0, 0, time.Local),
+		Day:      time.Date(2025, 3, 2, 0, 0, 0, 0, time.Local),
+		Quantity: &quantity,
+		Tags:     []string{"a", "b"},
+		IDs:      []uint{1, 2},
+	}
+	if !reflect.DeepEqual(form, want) {
+		t.Errorf("Bind got %+v, want %+v", form, want)
+	}
+}
+
+func TestBindEmptyValues(t *testing.T) {
+	r := bindRequest(url.Values{"name": {""}, "count": {" "}, "quantity": {""}}, "")
+	form := bindForm{Name: "kept", Count: 7}
+	if errs := Bind(r, &form); errs.Any() {
+		t.Fatalf("Bind returned errors %v", errs)
+	}
+	if form.Name != "kept" || form.Count != 7 || form.Quantity != nil || form.ID != 0 {
+		t.Errorf("empty values changed the form to %+v", form)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	tests := []struct {
+		field string
+		value string
+		key   string
+		msg   string
+	}{
+		{"active", "maybe", "active", "is not a valid boolean"},
+		{"count", "1.5", "count", "is not a valid whole number"},
+		{"count", "200", "count", "is not a valid whole number"},
+		{"total", "-1", "total", "is not a valid positive whole number"},
+		{"ratio", "abc", "ratio", "is not a valid number"},
+		{"price", "12.345", "price", "is not a valid value"},
+		{"date", "tomorrow", "date", "is not a valid date or time"},
+		{"day", "2025-03-02", "day", "is not a valid date or time"},
+		{"quantity", "x", "quantity", "is not a valid whole number"},
+		{"ids", "1,2", "ids", "is not a valid positive whole number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field+"="+tt.value, func(t *testing.T) {
+			var form bindForm
+			errs := Bind(bindRequest(url.Values{tt.field: {tt.value}}, ""), &form)
+			if got := errs.Get(tt.key); !reflect.DeepEqual(got, []string{tt.msg}) {
+				t.Errorf("errors for %s = %v, want %q", tt.key, got, tt.msg)
+			}
+			if form.Quantity != nil {
+				t.Errorf("a failed pointer was set to %d", *form.Quantity)
+			}
+		})
+	}
+
+	var form bindForm
+	errs := Bind(bindRequest(nil, "abc"), &form)
+	if got := errs.Get("id"); !reflect.DeepEqual(got, []string{"is not a valid positive whole number"}) {
+		t.Errorf("errors for an invalid URL parameter = %v", got)
+	}
+}
+
+func TestBindTarget(t *testing.T) {
+	for _, target := range []any{bindForm{}, new(string), nil} {
+		errs := Bind(bindRequest(nil, ""), target)
+		if !errs.Has("_error") {
+			t.Errorf("Bind(%T) got errors %v, want a target error", target, errs)
+		}
+	}
+}
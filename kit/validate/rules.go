@@ -0,0 +1,436 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+	"unicode"
+)
+
+var (
+	emailRegex = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
+	urlRegex   = regexp.MustCompile(`^(https?:\/\/)?(www\.)?([a-zA-Z0-9\-]+\.)+[a-zA-Z]{2,}(\/[a-zA-Z0-9\-._~:\/?#\[\]@!$&'()*+,;=]*)?$`)
+)
+
+// RuleSet holds the state of a single rule.
+type RuleSet struct {
+	Name       string
+	RuleValue  any
+	FieldValue any
+	FieldName  any
+	// Data is the struct being validated, for rules comparing fields.
+	Data         any
+	ErrorMessage string
+	MessageFunc  func(RuleSet) string
+	ValidateFunc func(RuleSet) bool
+}
+
+// Message overrides the default message of a RuleSet
+func (set RuleSet) Message(msg string) RuleSet {
+	set.ErrorMessage = msg
+	return set
+}
+
+type Numeric interface {
+	int | float64
+}
+
+func In[T any](values []T) RuleSet {
+	return RuleSet{
+		Name:      "in",
+		RuleValue: values,
+		ValidateFunc: func(set RuleSet) bool {
+			v, ok := set.FieldValue.(T)
+			if !ok {
+				return false
+			}
+			for _, value := range values {
+				if reflect.DeepEqual(v, value) {
+					return true
+				}
+			}
+			return false
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be in %v", values)
+		},
+	}
+}
+
+var ContainsUpper = RuleSet{
+	Name: "containsUpper",
+	ValidateFunc: func(rule RuleSet) bool {
+		str, ok := rule.FieldValue.(string)
+		if !ok {
+			return false
+		}
+		for _, ch := range str {
+			if unicode.IsUpper(rune(ch)) {
+				return true
+			}
+		}
+		return false
+	},
+	MessageFunc: func(set RuleSet) string {
+		return "must contain at least 1 uppercase character"
+	},
+}
+
+var ContainsDigit = RuleSet{
+	Name: "containsDigit",
+	ValidateFunc: func(rule RuleSet) bool {
+		str, ok := rule.FieldValue.(string)
+		if !ok {
+			return false
+		}
+		return hasDigit(str)
+	},
+	MessageFunc: func(set RuleSet) string {
+		return "must contain at least 1 numeric character"
+	},
+}
+
+var ContainsSpecial = RuleSet{
+	Name: "containsSpecial",
+	ValidateFunc: func(rule RuleSet) bool {
+		str, ok := rule.FieldValue.(string)
+		if !ok {
+			return false
+		}
+		return hasSpecialChar(str)
+	},
+	MessageFunc: func(set RuleSet) string {
+		return "must contain at least 1 special character"
+	},
+}
+
+// Required fails for empty strings and slices and for zero values of
+// other types.
+var Required = RuleSet{
+	Name: "required",
+	MessageFunc: func(set RuleSet) string {
+		return "is a required field"
+	},
+	ValidateFunc: func(rule RuleSet) bool {
+		val := reflect.ValueOf(rule.FieldValue)
+		if !val.IsValid() {
+			return false
+		}
+		switch val.Kind() {
+		case reflect.Slice, reflect.Map:
+			return val.Len() > 0
+		}
+		return !val.IsZero()
+	},
+}
+
+var URL = RuleSet{
+	Name: "url",
+	MessageFunc: func(set RuleSet) string {
+		return "is not a valid url"
+	},
+	ValidateFunc: func(set RuleSet) bool {
+		u, ok := set.FieldValue.(string)
+		if !ok {
+			return false
+		}
+		return urlRegex.MatchString(u)
+	},
+}
+
+var Email = RuleSet{
+	Name: "email",
+	MessageFunc: func(set RuleSet) string {
+		return "is not a valid email address"
+	},
+	ValidateFunc: func(set RuleSet) bool {
+		email, ok := set.FieldValue.(string)
+		if !ok {
+			return false
+		}
+		return emailRegex.MatchString(email)
+	},
+}
+
+var Time = RuleSet{
+	Name: "time",
+	ValidateFunc: func(set RuleSet) bool {
+		t, ok := set.FieldValue.(time.Time)
+		if !ok {
+			return false
+		}
+		return t.After(time.Time{})
+	},
+	MessageFunc: func(set RuleSet) string {
+		return "is not a valid time"
+	},
+}
+
+func TimeAfter(t time.Time) RuleSet {
+	return RuleSet{
+		Name: "timeAfter",
+		ValidateFunc: func(set RuleSet) bool {
+			value, ok := set.FieldValue.(time.Time)
+			if !ok {
+				return false
+			}
+			return value.After(t)
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("is not after %v", t)
+		},
+	}
+}
+
+func TimeBefore(t time.Time) RuleSet {
+	return RuleSet{
+		Name: "timeBefore",
+		ValidateFunc: func(set RuleSet) bool {
+			value, ok := set.FieldValue.(time.Time)
+			if !ok {
+				return false
+			}
+			return value.Before(t)
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("is not before %v", t)
+		},
+	}
+}
+
+func EQ[T comparable](v T) RuleSet {
+	return RuleSet{
+		Name:      "eq",
+		RuleValue: v,
+		ValidateFunc: func(set RuleSet) bool {
+			value, ok := set.FieldValue.(T)
+			return ok && value == v
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be equal to %v", v)
+		},
+	}
+}
+
+func LTE[T Numeric](n T) RuleSet {
+	return RuleSet{
+		Name:      "lte",
+		RuleValue: n,
+		ValidateFunc: func(set RuleSet) bool {
+			value, ok := toFloat(set.FieldValue)
+			return ok && value <= float64(n)
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be lesser or equal than %v", n)
+		},
+	}
+}
+
+func GTE[T Numeric](n T) RuleSet {
+	return RuleSet{
+		Name:      "gte",
+		RuleValue: n,
+		ValidateFunc: func(set RuleSet) bool {
+			value, ok := toFloat(set.FieldValue)
+			return ok && value >= float64(n)
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be greater or equal than %v", n)
+		},
+	}
+}
+
+func LT[T Numeric](n T) RuleSet {
+	return RuleSet{
+		Name:      "lt",
+		RuleValue: n,
+		ValidateFunc: func(set RuleSet) bool {
+			value, ok := toFloat(set.FieldValue)
+			return ok && value < float64(n)
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be lesser than %v", n)
+		},
+	}
+}
+
+func GT[T Numeric](n T) RuleSet {
+	return RuleSet{
+		Name:      "gt",
+		RuleValue: n,
+		ValidateFunc: func(set RuleSet) bool {
+			value, ok := toFloat(set.FieldValue)
+			return ok && value > float64(n)
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be greater than %v", n)
+		},
+	}
+}
+
+// Max limits the length of strings and slices and the value of numbers.
+func Max(n int) RuleSet {
+	return RuleSet{
+		Name:      "max",
+		RuleValue: n,
+		ValidateFunc: func(set RuleSet) bool {
+			size, ok := sizeOf(set.FieldValue)
+			return ok && size <= float64(n)
+		},
+		MessageFunc: func(set RuleSet) string {
+			switch set.FieldValue.(type) {
+			case string:
+				return fmt.Sprintf("should be maximum %d characters long", n)
+			}
+			if _, ok := toFloat(set.FieldValue); ok {
+				return fmt.Sprintf("should be at most %d", n)
+			}
+			return fmt.Sprintf("should have at most %d items", n)
+		},
+	}
+}
+
+// Min is the lower limit for the length of strings and slices and the
+// value of numbers.
+func Min(n int) RuleSet {
+	return RuleSet{
+		Name:      "min",
+		RuleValue: n,
+		ValidateFunc: func(set RuleSet) bool {
+			size, ok := sizeOf(set.FieldValue)
+			return ok && size >= float64(n)
+		},
+		MessageFunc: func(set RuleSet) string {
+			switch set.FieldValue.(type) {
+			case string:
+				return fmt.Sprintf("should be at least %d characters long", n)
+			}
+			if _, ok := toFloat(set.FieldValue); ok {
+				return fmt.Sprintf("should be at least %d", n)
+			}
+			return fmt.Sprintf("should have at least %d items", n)
+		},
+	}
+}
+
+// Future fails for times that are not after the moment of validation.
+var Future = RuleSet{
+	Name: "future",
+	ValidateFunc: func(set RuleSet) bool {
+		t, ok := set.FieldValue.(time.Time)
+		return ok && t.After(time.Now())
+	},
+	MessageFunc: func(set RuleSet) string {
+		return "cannot be in the past"
+	},
+}
+
+// EqualTo fails if the value differs from the field with the given name,
+// e.g. a password confirmation from the password.
+func EqualTo(field string) RuleSet {
+	return RuleSet{
+		Name:      "equalTo",
+		RuleValue: field,
+		ValidateFunc: func(set RuleSet) bool {
+			_, other, ok := lookupField(set.Data, field)
+			return ok && reflect.DeepEqual(set.FieldValue, other.Interface())
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be equal to %s", field)
+		},
+	}
+}
+
+// After fails unless the time is after the time in the field with the
+// given name, e.g. an end time after the start time. It passes when the
+// other field is not set, which is left to its own rules.
+func After(field string) RuleSet {
+	return RuleSet{
+		Name:      "after",
+		RuleValue: field,
+		ValidateFunc: func(set RuleSet) bool {
+			value, other, ok := timeFields(set, field)
+			return ok && (other.IsZero() || value.After(other))
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be after %s", field)
+		},
+	}
+}
+
+// Before fails unless the time is before the time in the field with the
+// given name. It passes when the other field is not set.
+func Before(field string) RuleSet {
+	return RuleSet{
+		Name:      "before",
+		RuleValue: field,
+		ValidateFunc: func(set RuleSet) bool {
+			value, other, ok := timeFields(set, field)
+			return ok && (other.IsZero() || value.Before(other))
+		},
+		MessageFunc: func(set RuleSet) string {
+			return fmt.Sprintf("should be before %s", field)
+		},
+	}
+}
+
+func timeFields(set RuleSet, field string) (time.Time, time.Time, bool) {
+	value, ok := set.FieldValue.(time.Time)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	_, other, ok := lookupField(set.Data, field)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	otherTime, ok := other.Interface().(time.Time)
+	return value, otherTime, ok
+}
+
+// toFloat converts any int, uint or float kind, or a pointer to one, to a
+// float64.
+func toFloat(v any) (float64, bool) {
+	val := reflect.Indirect(reflect.ValueOf(v))
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	}
+	return 0, false
+}
+
+// sizeOf returns the length of strings and slices and the value of
+// numbers.
+func sizeOf(v any) (float64, bool) {
+	if n, ok := toFloat(v); ok {
+		return n, true
+	}
+	val := reflect.Indirect(reflect.ValueOf(v))
+	switch val.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(val.Len()), true
+	}
+	return 0, false
+}
+
+func hasDigit(s string) bool {
+	for _, char := range s {
+		if unicode.IsDigit(char) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSpecialChar(s string) bool {
+	for _, char := range s {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package validate
+
+import (
+	"testing"
+	"time"
+)
+
+type rulesForm struct {
+	Password        string
+	PasswordConfirm string
+	Start           time.Time
+	End             time.Time
+}
+
+func TestMinMax(t *testing.T) {
+	count := 3
+	tests := []struct {
+		name  string
+		rule  RuleSet
+		value any
+		ok    bool
+		msg   string
+	}{
+		{"string at the minimum", Min(3), "abc", true, ""},
+		{"short string", Min(3), "ab", false, "should be at least 3 characters long"},
+		{"long string", Max(3), "abcd", false, "should be maximum 3 characters long"},
+		{"number at the minimum", Min(3), 3, true, ""},
+		{"small number", Min(3), 2, false, "should be at least 3"},
+		{"large number", Max(10), 11, false, "should be at most 10"},
+		{"number as a string is a length", Max(2), "100", false, "should be maximum 2 characters long"},
+		{"uint", Max(10), uint(10), true, ""},
+		{"float", Min(1), 0.5, false, "should be at least 1"},
+		{"pointer to a number", Max(2), &count, false, "should be at most 2"},
+		{"slice", Min(1), []string{}, false, "should have at least 1 items"},
+		{"slice at the maximum", Max(2), []int{1, 2}, true, ""},
+		{"unsupported kind", Max(2), true, false, "should have at most 2 items"},
+		{"missing value", Min(1), nil, false, "should have at least 1 items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := tt.rule
+			set.FieldValue = tt.value
+			if ok := set.ValidateFunc(set); ok != tt.ok {
+				t.Fatalf("%s(%v) = %v, want %v", set.Name, tt.value, ok, tt.ok)
+			}
+			if msg := set.MessageFunc(set); !tt.ok && msg != tt.msg {
+				t.Errorf("message = %q, want %q", msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestComparisonRules(t *testing.T) {
+	now := time.Now()
+	schema := Schema{
+		"passwordConfirm": Rules(EqualTo("password")),
+		"end":             Rules(After("start")),
+		"start":           Rules(Before("end")),
+	}
+	tests := []struct {
+		name string
+		form rulesForm
+		errs []string
+	}{
+		{"valid", rulesForm{"secret", "secret", now, now.Add(time.Hour)}, nil},
+		{"different confirmation", rulesForm{"secret", "other", now, now.Add(time.Hour)}, []string{"passwordConfirm"}},
+		{"end before start", rulesForm{"secret", "secret", now, now.Add(-time.Hour)}, []string{"end", "start"}},
+		{"same times", rulesForm{"secret", "secret", now, now}, []string{"end", "start"}},
+		// The rules pass when the other field is not set, but a zero time
+		// is not after anything.
+		{"no start", rulesForm{End: now}, nil},
+		{"no end", rulesForm{Start: now}, []string{"end"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, ok := Validate(&tt.form, schema)
+			if ok != (len(tt.errs) == 0) || len(errs) != len(tt.errs) {
+				t.Fatalf("Validate = %v, %v, want errors for %v", errs, ok, tt.errs)
+			}
+			for _, key := range tt.errs {
+				if !errs.Has(key) {
+					t.Errorf("no error for %s in %v", key, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestComparisonRulesUnknownField(t *testing.T) {
+	form := rulesForm{End: time.Now()}
+	errs, ok := Validate(form, Schema{
+		"end":      Rules(After("missing")),
+		"password": Rules(EqualTo("missing")),
+	})
+	if ok || !errs.Has("end") || !errs.Has("password") {
+		t.Errorf("Validate = %v, %v, want errors for end and password", errs, ok)
+	}
+}
+
+func TestToFloat(t *testing.T) {
+	n := int16(-4)
+	tests := []struct {
+		value any
+		want  float64
+		ok    bool
+	}{
+		{int(3), 3, true},
+		{int64(-2), -2, true},
+		{uint8(255), 255, true},
+		{float32(0.5), 0.5, true},
+		{&n, -4, true},
+		{"3", 0, false},
+		{nil, 0, false},
+		{(*int)(nil), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := toFloat(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("toFloat(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSizeOf(t *testing.T) {
+	s := "abcd"
+	tests := []struct {
+		value any
+		want  float64
+		ok    bool
+	}{
+		{"abc", 3, true},
+		{&s, 4, true},
+		{[]int{1, 2}, 2, true},
+		{map[string]int{"a": 1}, 1, true},
+		{7, 7, true},
+		{struct{}{}, 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := sizeOf(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("sizeOf(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package validate binds request data to typed structs and validates them
+// against a Schema. It started as a copy of superkit's validate package
+// and keeps its API, so Schema, Rules and Errors work as before.
+package validate
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Errors is a map holding all the possible errors that may
+// occur during validation.
+type Errors map[string][]string
+
+// Any return true if there is any error.
+func (e Errors) Any() bool {
+	return len(e) > 0
+}
+
+// Add adds an error for a specific field
+func (e Errors) Add(field string, msg string) {
+	if _, ok := e[field]; !ok {
+		e[field] = []string{}
+	}
+	e[field] = append(e[field], msg)
+}
+
+// Get returns all the errors for the given field.
+func (e Errors) Get(field string) []string {
+	return e[field]
+}
+
+// Has returns true whether the given field has any errors.
+func (e Errors) Has(field string) bool {
+	return len(e[field]) > 0
+}
+
+// Schema represents a validation schema. Its keys name struct fields by
+// their form tag or by their Go name.
+type Schema map[string][]RuleSet
+
+// Merge merges the two given schemas, returning a new Schema.
+func Merge(schema, other Schema) Schema {
+	newSchema := Schema{}
+	maps.Copy(newSchema, schema)
+	maps.Copy(newSchema, other)
+	return newSchema
+}
+
+// Rules is a function that takes any amount of RuleSets
+func Rules(rules ...RuleSet) []RuleSet {
+	ruleSets := make([]RuleSet, len(rules))
+	for i := 0; i < len(ruleSets); i++ {
+		ruleSets[i] = rules[i]
+	}
+	return ruleSets
+}
+
+// Validate validates data based on the given Schema.
+func Validate(data any, fields Schema) (Errors, bool) {
+	errors := Errors{}
+	return validate(data, fields, errors)
+}
+
+// Request binds the request into data and validates it based on the
+// given schema. Fields that could not be bound report a binding error
+// and are not validated any further.
+func Request(r *http.Request, data any, schema Schema) (Errors, bool) {
+	errors := Bind(r, data)
+	return validate(data, schema, errors)
+}
+
+func validate(data any, schema Schema, errors Errors) (Errors, bool) {
+	ok := !errors.Any()
+	for name, ruleSets := range schema {
+		field, fieldValue, found := lookupField(data, name)
+		key := name
+		if found {
+			key = errorKey(field)
+		}
+		// Don't pile rule errors on top of a binding error.
+		if errors.Has(key) {
+			continue
+		}
+		var value any
+		if found {
+			value = fieldValue.Interface()
+		}
+		for _, set := range ruleSets {
+			set.FieldValue = value
+			set.FieldName = key
+			set.Data = data
+			if !set.ValidateFunc(set) {
+				ok = false
+				msg := set.MessageFunc(set)
+				if len(set.ErrorMessage) > 0 {
+					msg = set.ErrorMessage
+				}
+				errors.Add(key, msg)
+			}
+		}
+	}
+	return errors, ok
+}
+
+// lookupField finds the struct field for a schema key. The key matches
+// the form tag of a field, its name, or its name with the first letter
+// uppercased.
+func lookupField(data any, name string) (reflect.StructField, reflect.Value, bool) {
+	val := reflect.ValueOf(data)
+	for val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct || len(name) == 0 {
+		return reflect.StructField{}, reflect.Value{}, false
+	}
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.IsExported() && formName(field) == name {
+			return field, val.Field(i), true
+		}
+	}
+	for _, candidate := range []string{name, upperFirst(name)} {
+		if field, ok := typ.FieldByName(candidate); ok && field.IsExported() {
+			return field, val.FieldByIndex(field.Index), true
+		}
+	}
+	return reflect.StructField{}, reflect.Value{}, false
+}
+
+// errorKey returns the key errors of a field are stored under: its form
+// tag, or its name with the first letter lowercased.
+func errorKey(field reflect.StructField) string {
+	if name := formName(field); name != "-" {
+		return name
+	}
+	return lowerFirst(field.Name)
+}
+
+// formName returns the name of the request value bound to the field, or
+// "-" if the field is never bound.
+func formName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+	if len(name) == 0 {
+		return lowerFirst(field.Name)
+	}
+	return name
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// lowerFirst lowercases the first letter of s, or all of it when s is
+// an initialism like ID or PIN.
+func lowerFirst(s string) string {
+	if isUppercase(s) {
+		return strings.ToLower(s)
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func isUppercase(s string) bool {
+	for _, ch := range s {
+		if !unicode.IsUpper(rune(ch)) {
+			return false
+		}
+	}
+	return true
+}
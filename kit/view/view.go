@@ -0,0 +1,116 @@
+package view
+
+import (
+	"context"
+	"fmt"
+	"gothstack/kit"
+	"gothstack/kit/middleware"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Asset is a view helper that returns the full asset path as a
+// string based on the given asset name.
+//
+//	view.Asset("styles.css") // => /public/assets/styles.css.
+func Asset(name string) string {
+	return fmt.Sprintf("/public/assets/%s", name)
+}
+
+// getContextValue is a helper function to retrieve a value from the context.
+// It returns the value if present, otherwise returns the provided default value.
+func getContextValue[T any](ctx context.Context, key interface{}, defaultValue T) T {
+	value, ok := ctx.Value(key).(T)
+	if !ok {
+		return defaultValue
+	}
+	return value
+}
+
+// Auth is a view helper function that returns the current Auth.
+// If Auth is not set, a default Auth will be returned.
+//
+//	view.Auth(ctx)
+func Auth(ctx context.Context) kit.Auth {
+	return getContextValue(ctx, kit.AuthKey{}, kit.DefaultAuth{})
+}
+
+// CSRFToken is a view helper that returns the CSRF token of the current
+// visitor. It is empty if the kit.WithCSRF middleware is not used.
+//
+//	view.CSRFToken(ctx)
+func CSRFToken(ctx context.Context) string {
+	return getContextValue(ctx, kit.CSRFKey{}, "")
+}
+
+// URL is a view helper that returns the current URL.
+// The request path can be accessed with:
+//
+//	view.URL(ctx).Path // => ex. /login
+func URL(ctx context.Context) *url.URL {
+	return getContextValue(ctx, middleware.RequestKey{}, &http.Request{}).URL
+}
+
+// Request is a view helper that returns the current http request.
+// The request can be accessed with:
+//
+//	view.Request(ctx)
+func Request(ctx context.Context) *http.Request {
+	return getContextValue(ctx, middleware.RequestKey{}, &http.Request{})
+}
+
+// InputValue is a view helper that formats a typed form value for the
+// value attribute of an input. Zero values are empty, so fresh forms show
+// their placeholders, and times use the datetime-local format. Pointers
+// are empty if nil and show their value otherwise, even if it is zero.
+//
+//	view.InputValue(values.Price) // => 12.50
+func InputValue(value any) string {
+	if value == nil || reflect.ValueOf(value).IsZero() {
+		return ""
+	}
+	if val := reflect.ValueOf(value); val.Kind() == reflect.Ptr {
+		value = val.Elem().Interface()
+		if t, ok := value.(time.Time); ok && t.IsZero() {
+			return ""
+		}
+	}
+	switch value := value.(type) {
+	case time.Time:
+		return value.Format("2006-01-02T15:04")
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	}
+	return fmt.Sprint(value)
+}
+
+// DateValue is a view helper that formats a time for a date input.
+//
+//	view.DateValue(values.MealDate) // => 2025-03-14
+func DateValue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// RequestID is a view helper that returns the ID of the current request,
+// which support can look up in the logs.
+//
+//	view.RequestID(ctx)
+func RequestID(ctx context.Context) string {
+	return kit.RequestID(ctx)
+}
+
+// Flashes is a view helper that returns the flash messages to show on
+// the current page.
+//
+//	view.Flashes(ctx)
+func Flashes(ctx context.Context) []kit.Flash {
+	return kit.Flashes(ctx)
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"gothstack/kit"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the activity log of a user.
+const (
+	ActivityLogin             = "login"
+	ActivityLoginFailed       = "login.failed"
+	ActivityPasswordReset     = "password.reset"
+	ActivityPasswordResetSent = "password.reset.sent"
+	ActivityEmailChanged      = "email.changed"
+	ActivityEmailVerified     = "email.verified"
+	ActivityTwoFactorEnabled  = "2fa.enabled"
+	ActivityTwoFactorDisabled = "2fa.disabled"
+	ActivityRoleChanged       = "role.changed"
+	ActivityAccountDisabled   = "account.disabled"
+	ActivityAccountEnabled    = "account.enabled"
+	ActivitySessionsRevoked   = "sessions.revoked"
+)
+
+// UserActivity is an entry in the activity log of a user. ActorID is set
+// when someone else, like an admin, acted on the account.
+type UserActivity struct {
+	gorm.Model
+
+	UserID    uint
+	ActorID   *uint
+	Action    string
+	Detail    string
+	IPAddress string
+	Actor     *User
+}
+
+// recordActivity adds an entry to the activity log of the given user. The
+// logged in user of the request is recorded as the actor if it's someone
+// else. Failing to record activity never fails the request.
+func recordActivity(kit *kit.Kit, userID uint, action, detail string) {
+	activity := UserActivity{
+		UserID:    userID,
+		Action:    action,
+		Detail:    detail,
+		IPAddress: clientIP(kit.Request),
+	}
+	if auth, ok := kit.Auth().(Auth); ok && auth.Check() && auth.UserID != userID {
+		activity.ActorID = &auth.UserID
+	}
+	if err := db.Get().Create(&activity).Error; err != nil {
+		slog.Error("failed to record user activity", "action", action, "user", userID, "err", err)
+	}
+}
+
+// recentActivity returns the latest activity of the given user, newest first.
+func recentActivity(userID uint, limit int) ([]UserActivity, error) {
+	var activities []UserActivity
+	err := db.Get().
+		Preload("Actor").
+		Where("user_id = ?", userID).
+		Order("created_at desc").
+		Limit(limit).
+		Find(&activities).Error
+	return activities, err
+}
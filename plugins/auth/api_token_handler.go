@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"slices"
+	"time"
+)
+
+var apiTokenSchema = v.Schema{
+	"name":          v.Rules(v.Min(2), v.Max(50)),
+	"expiresInDays": v.Rules(v.In([]int{0, 30, 90, 365})),
+}
+
+// HandleAPITokenCreate creates a personal access token. The token is shown
+// once in the response and can't be retrieved later.
+func HandleAPITokenCreate(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	var values APITokenFormValues
+	errors, ok := v.Request(kit.Request, &values, apiTokenSchema)
+
+	// Tokens can only be limited to permissions the user has.
+	available := PermissionsForRole(auth.Role)
+	scopes := kit.Request.PostForm["scopes"]
+	for _, scope := range scopes {
+		if !slices.Contains(available, scope) {
+			errors.Add("scopes", "unknown scope "+scope)
+			ok = false
+		}
+	}
+
+	data, err := apiTokenSectionData(auth)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return kit.Render(APITokenSection(data, values, errors))
+	}
+
+	ttl := time.Hour * 24 * time.Duration(values.ExpiresInDays)
+	token, err := createAPIToken(auth.UserID, values.Name, scopes, ttl)
+	if err != nil {
+		return err
+	}
+
+	data, err = apiTokenSectionData(auth)
+	if err != nil {
+		return err
+	}
+	data.NewToken = token
+	return kit.Render(APITokenSection(data, APITokenFormValues{ExpiresInDays: 90}, v.Errors{}))
+}
+
+// HandleAPITokenDelete revokes one of the authenticated user's tokens.
+func HandleAPITokenDelete(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	id, err := kit.URLParamID("id")
+	if err != nil {
+		return err
+	}
+	err = db.Get().Unscoped().
+		Where("id = ? AND user_id = ?", id, auth.UserID).
+		Delete(&APIToken{}).Error
+	if err != nil {
+		return err
+	}
+
+	data, err := apiTokenSectionData(auth)
+	if err != nil {
+		return err
+	}
+	return kit.Render(APITokenSection(data, APITokenFormValues{ExpiresInDays: 90}, v.Errors{}))
+}
+
+func apiTokenSectionData(auth Auth) (APITokenSectionData, error) {
+	tokens, err := userAPITokens(auth.UserID)
+	return APITokenSectionData{
+		Tokens:          tokens,
+		AvailableScopes: PermissionsForRole(auth.Role),
+	}, err
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How a request was authenticated, see Auth.Method.
+const (
+	AuthMethodSession = "session"
+	AuthMethodToken   = "token"
+)
+
+const (
+	apiTokenPrefix = "gsk_"
+	// apiTokenTouchInterval limits how often the last use of a token is
+	// written so not every API request causes a write.
+	apiTokenTouchInterval = time.Minute
+)
+
+// APIToken is a personal access token used by scripts and devices to call
+// the API on behalf of a user. Only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	gorm.Model
+
+	UserID      uint
+	Name        string
+	TokenPrefix string
+	TokenHash   string
+	Scopes      string
+	ExpiresAt   sql.NullTime
+	LastUsedAt  sql.NullTime
+	User        User
+}
+
+// ScopeList returns the permissions the token is limited to.
+func (token APIToken) ScopeList() []string {
+	return strings.Fields(token.Scopes)
+}
+
+// Expired returns true if the token has an expiry that has passed.
+func (token APIToken) Expired() bool {
+	return token.ExpiresAt.Valid && token.ExpiresAt.Time.Before(time.Now())
+}
+
+// Authenticate authenticates requests with a bearer token in the
+// Authorization header and falls back to the cookie session otherwise.
+func Authenticate(kit *kit.Kit) (kit.Auth, error) {
+	if _, ok := bearerToken(kit.Request); ok {
+		return AuthenticateToken(kit)
+	}
+	return AuthenticateUser(kit)
+}
+
+// AuthenticateToken authenticates the request with the API token in the
+// Authorization header. The permissions of the user are limited to the
+// scopes of the token, and the request only reaches routes that require
+// one of them, see kit.ScopedAuth.
+func AuthenticateToken(kit *kit.Kit) (kit.Auth, error) {
+	auth := Auth{}
+	token, ok := bearerToken(kit.Request)
+	if !ok {
+		return auth, nil
+	}
+
+	var apiToken APIToken
+	err := db.Get().
+		Preload("User").
+		Find(&apiToken, "token_hash = ?", hashToken(token)).Error
+	if err != nil || apiToken.ID == 0 || apiToken.Expired() || apiToken.User.Disabled() ||
+		twoFactorMissing(apiToken.User) {
+		return auth, nil
+	}
+
+	if !apiToken.LastUsedAt.Valid || time.Since(apiToken.LastUsedAt.Time) > apiTokenTouchInterval {
+		db.Get().Model(&apiToken).Update("last_used_at", time.Now())
+	}
+
+	var permissions []string
+	for _, permission := range PermissionsForRole(apiToken.User.Role) {
+		if slices.Contains(apiToken.ScopeList(), permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return Auth{
+		LoggedIn:    true,
+		UserID:      apiToken.User.ID,
+		Email:       apiToken.User.Email,
+		Role:        apiToken.User.Role,
+		Permissions: permissions,
+		Method:      AuthMethodToken,
+	}, nil
+}
+
+// withSessionAuth rejects requests authenticated with an API token. Account
+// settings, including the tokens themselves, can only be changed from a
+// browser session.
+func withSessionAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kit := &kit.Kit{
+			Response: w,
+			Request:  r,
+		}
+		if auth, ok := kit.Auth().(Auth); ok && auth.IsTokenRequest() {
+			kit.Forbidden()
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// createAPIToken creates a token for the user and returns it in plain text.
+// A zero ttl creates a token that never expires.
+func createAPIToken(userID uint, name string, scopes []string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	apiToken := APIToken{
+		UserID:      userID,
+		Name:        name,
+		TokenPrefix: token[:len(apiTokenPrefix)+8],
+		TokenHash:   hashToken(token),
+		Scopes:      strings.Join(scopes, " "),
+	}
+	if ttl > 0 {
+		apiToken.ExpiresAt = sql.NullTime{Time: time.Now().Add(ttl), Valid: true}
+	}
+	return token, db.Get().Create(&apiToken).Error
+}
+
+// userAPITokens returns the tokens of the given user, newest first.
+func userAPITokens(userID uint) ([]APIToken, error) {
+	var tokens []APIToken
+	err := db.Get().
+		Where("user_id = ?", userID).
+		Order("created_at desc").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := kit.BearerToken(r)
+	return token, ok && strings.HasPrefix(token, apiTokenPrefix)
+}
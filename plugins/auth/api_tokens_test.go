@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	setupTestDB(t)
+	staff := createTestUser(t, "staff@example.com")
+	disabled := createTestUser(t, "disabled@example.com")
+	err := db.Get().Model(&User{}).
+		Where("id IN ?", []uint{staff.ID, disabled.ID}).
+		Update("role", RoleStaff).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get().Model(&disabled).
+		Update("disabled_at", sql.NullTime{Time: time.Now(), Valid: true}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newToken := func(userID uint, scopes []string, ttl time.Duration) string {
+		token, err := createAPIToken(userID, "test", scopes, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	// Tokens can't outlive their expiry, so create an expired one by hand.
+	expired := newToken(staff.ID, []string{PermissionManageMeals}, time.Hour)
+	err = db.Get().Model(&APIToken{}).
+		Where("token_hash = ?", hashToken(expired)).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		header      string
+		loggedIn    bool
+		permissions []string
+	}{
+		{
+			name:        "scopes the role grants",
+			header:      "Bearer " + newToken(staff.ID, []string{PermissionManageMeals, PermissionViewOrders}, 0),
+			loggedIn:    true,
+			permissions: []string{PermissionManageMeals, PermissionViewOrders},
+		},
+		{
+			name:        "scopes the role doesn't grant are dropped",
+			header:      "Bearer " + newToken(staff.ID, []string{PermissionManageMeals, PermissionManageUsers}, 0),
+			loggedIn:    true,
+			permissions: []string{PermissionManageMeals},
+		},
+		{
+			name:     "no scopes",
+			header:   "Bearer " + newToken(staff.ID, nil, 0),
+			loggedIn: true,
+		},
+		{
+			name:   "expired token",
+			header: "Bearer " + expired,
+		},
+		{
+			name:   "disabled user",
+			header: "Bearer " + newToken(disabled.ID, []string{PermissionManageMeals}, 0),
+		},
+		{
+			name:   "unknown token",
+			header: "Bearer " + apiTokenPrefix + "unknown",
+		},
+		{
+			name:   "no token",
+			header: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/orders", nil)
+			r.Header.Set("Authorization", tt.header)
+			got, err := AuthenticateToken(&kit.Kit{Response: httptest.NewRecorder(), Request: r})
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth := got.(Auth)
+			if auth.Check() != tt.loggedIn {
+				t.Fatalf("Check() = %v, want %v", auth.Check(), tt.loggedIn)
+			}
+			if !tt.loggedIn {
+				return
+			}
+			if !slices.Equal(auth.Permissions, tt.permissions) {
+				t.Errorf("permissions = %v, want %v", auth.Permissions, tt.permissions)
+			}
+			if !auth.Scoped() || !auth.IsTokenRequest() {
+				t.Error("token request is not scoped")
+			}
+			if auth.HasRole(RoleStaff) {
+				t.Error("HasRole is true for a token request")
+			}
+		})
+	}
+}
+
+func TestAuthHasRole(t *testing.T) {
+	tests := []struct {
+		name string
+		auth Auth
+		role string
+		want bool
+	}{
+		{"session with the role", Auth{LoggedIn: true, Role: RoleAdmin, Method: AuthMethodSession}, RoleAdmin, true},
+		{"session with another role", Auth{LoggedIn: true, Role: RoleStaff, Method: AuthMethodSession}, RoleAdmin, false},
+		{"token with the role", Auth{LoggedIn: true, Role: RoleAdmin, Method: AuthMethodToken}, RoleAdmin, false},
+		{"logged out", Auth{Role: RoleAdmin}, RoleAdmin, false},
+	}
+	for _, tt := range tests {
+		if got := tt.auth.HasRole(tt.role); got != tt.want {
+			t.Errorf("%s: HasRole(%q) = %v, want %v", tt.name, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestWithSessionAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name string
+		auth Auth
+		want int
+	}{
+		{"session", Auth{LoggedIn: true, Method: AuthMethodSession}, http.StatusOK},
+		{"token", Auth{LoggedIn: true, Method: AuthMethodToken}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/profile", nil)
+		r = r.WithContext(context.WithValue(r.Context(), kit.AuthKey{}, tt.auth))
+		w := httptest.NewRecorder()
+		withSessionAuth(ok).ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
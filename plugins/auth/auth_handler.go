@@ -1,17 +1,17 @@
 package auth
 
 import (
-	"gothstack/app/db"
 	"database/sql"
+	"errors"
+	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"math"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -27,10 +27,11 @@ var authSchema = v.Schema{
 
 func HandleLoginIndex(kit *kit.Kit) error {
 	if kit.Auth().Check() {
-		redirectURL := kit.Getenv("SUPERKIT_AUTH_REDIRECT_AFTER_LOGIN", "/profile")
-		return kit.Redirect(http.StatusSeeOther, redirectURL)
+		return kit.Redirect(http.StatusSeeOther, loginRedirectURL(kit))
 	}
-	return kit.Render(LoginIndex(LoginIndexPageData{}))
+	return kit.Render(LoginIndex(LoginIndexPageData{
+		Providers: oidcProviders(),
+	}))
 }
 
 func HandleLoginCreate(kit *kit.Kit) error {
@@ -40,49 +41,103 @@ func HandleLoginCreate(kit *kit.Kit) error {
 		return kit.Render(LoginForm(values, errors))
 	}
 
+	lockedUntil, locked, err := loginLockedUntil(accountThrottleKey(values.Email), ipThrottleKey(clientIP(kit.Request)))
+	if err != nil {
+		return err
+	}
+	if locked {
+		errors.Add("credentials", lockoutMessage(lockedUntil))
+		return kit.Render(LoginForm(values, errors))
+	}
+
 	var user User
-	err := db.Get().Find(&user, "email = ?", values.Email).Error
+	err = db.Get().First(&user, "email = ?", values.Email).Error
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			errors.Add("credentials", "invalid credentials")
-			return kit.Render(LoginForm(values, errors))
+		if err != gorm.ErrRecordNotFound {
+			return err
 		}
+		// Compare anyway so unknown emails take as long as wrong
+		// passwords and can't be told apart by the response time.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(values.Password))
+		if err := recordLoginFailure(kit, values.Email, user); err != nil {
+			return err
+		}
+		errors.Add("credentials", "invalid credentials")
+		return kit.Render(LoginForm(values, errors))
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(values.Password))
 	if err != nil {
+		if err := recordLoginFailure(kit, values.Email, user); err != nil {
+			return err
+		}
 		errors.Add("credentials", "invalid credentials")
 		return kit.Render(LoginForm(values, errors))
 	}
+	if err := rehashIfWeak(user.ID, "password_hash", user.PasswordHash, values.Password); err != nil {
+		return err
+	}
 
-	skipVerify := kit.Getenv("SUPERKIT_AUTH_SKIP_VERIFY", "false")
-	if skipVerify != "true" {
+	if !config.Auth.SkipVerify {
 		if !user.EmailVerifiedAt.Valid {
 			errors.Add("verified", "please verify your email")
 			return kit.Render(LoginForm(values, errors))
 		}
 	}
 
-	sessionExpiryStr := kit.Getenv("SUPERKIT_AUTH_SESSION_EXPIRY_IN_HOURS", "48")
-	sessionExpiry, err := strconv.Atoi(sessionExpiryStr)
-	if err != nil {
-		sessionExpiry = 48
+	return completeLogin(kit, user)
+}
+
+// completeLogin is called once the user proved their identity with any of
+// the login methods. It starts the second login step if the user needs one
+// and otherwise creates the session.
+func completeLogin(kit *kit.Kit, user User) error {
+	if user.Disabled() {
+		return kit.Redirect(http.StatusSeeOther, "/account/disabled")
 	}
-	session := Session{
-		UserID:    user.ID,
-		Token:     uuid.New().String(),
-		ExpiresAt: time.Now().Add(time.Hour * time.Duration(sessionExpiry)),
+	if user.TwoFactorEnabled() || twoFactorRequired(user.Role) {
+		return beginTwoFactor(kit, user)
 	}
-	if err = db.Get().Create(&session).Error; err != nil {
+	if err := clearLoginFailures(accountThrottleKey(user.Email)); err != nil {
 		return err
 	}
+	if err := createSession(kit, user); err != nil {
+		return err
+	}
+	return kit.Redirect(http.StatusSeeOther, loginRedirectURL(kit))
+}
 
-	sess := kit.GetSession(userSessionName)
-	sess.Values["sessionToken"] = session.Token
-	sess.Save(kit.Request, kit.Response)
-	redirectURL := kit.Getenv("SUPERKIT_AUTH_REDIRECT_AFTER_LOGIN", "/profile")
+// HandleAccountUnlock lifts a lockout using the link that was mailed to
+// the owner of the locked account.
+func HandleAccountUnlock(kit *kit.Kit) error {
+	token := kit.Request.URL.Query().Get("token")
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, TokenPurposeAccountUnlock, token)
+		if err != nil {
+			return err
+		}
+		return unlockAccount(tx, userToken.User, clientIP(kit.Request))
+	})
+	if errors.Is(err, errInvalidToken) {
+		return kit.Render(EmailVerificationError("Unlock link is invalid or has expired"))
+	}
+	if err != nil {
+		return err
+	}
+
+	return kit.Redirect(http.StatusSeeOther, "/login")
+}
 
-	return kit.Redirect(http.StatusSeeOther, redirectURL)
+func lockoutMessage(lockedUntil time.Time) string {
+	minutes := int(math.Ceil(time.Until(lockedUntil).Minutes()))
+	if minutes <= 1 {
+		return "too many failed login attempts, try again in a minute"
+	}
+	return fmt.Sprintf("too many failed login attempts, try again in %d minutes", minutes)
+}
+
+func loginRedirectURL(kit *kit.Kit) string {
+	return config.Auth.RedirectAfterLogin
 }
 
 func HandleLoginDelete(kit *kit.Kit) error {
@@ -98,34 +153,23 @@ func HandleLoginDelete(kit *kit.Kit) error {
 	return kit.Redirect(http.StatusSeeOther, "/")
 }
 
+// HandleAccountDisabled tells the user that their account was disabled.
+func HandleAccountDisabled(kit *kit.Kit) error {
+	return kit.Render(EmailVerificationError("This account has been disabled. Please contact us if you think this is a mistake."))
+}
+
 func HandleEmailVerify(kit *kit.Kit) error {
 	tokenStr := kit.Request.URL.Query().Get("token")
 	if len(tokenStr) == 0 {
 		return kit.Render(EmailVerificationError("invalid verification token"))
 	}
 
-	token, err := jwt.ParseWithClaims(
-		tokenStr, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
-			return []byte(os.Getenv("SUPERKIT_SECRET")), nil
-		}, jwt.WithLeeway(5*time.Second))
-	if err != nil {
-		return kit.Render(EmailVerificationError("invalid verification token"))
-	}
-	if !token.Valid {
-		return kit.Render(EmailVerificationError("invalid verification token"))
-	}
-
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		return kit.Render(EmailVerificationError("invalid verification token"))
-	}
-	if claims.ExpiresAt.Time.Before(time.Now()) {
+	userID, err := parseSignedUserToken(tokenStr, emailVerifyTokenAudience)
+	if errors.Is(err, jwt.ErrTokenExpired) {
 		return kit.Render(EmailVerificationError("Email verification token expired"))
 	}
-
-	userID, err := strconv.Atoi(claims.Subject)
 	if err != nil {
-		return kit.Render(EmailVerificationError("Email verification token expired"))
+		return kit.Render(EmailVerificationError("invalid verification token"))
 	}
 
 	var user User
@@ -144,6 +188,7 @@ func HandleEmailVerify(kit *kit.Kit) error {
 	if err != nil {
 		return err
 	}
+	recordActivity(kit, user.ID, ActivityEmailVerified, "")
 
 	return kit.Redirect(http.StatusSeeOther, "/login")
 }
@@ -160,13 +205,16 @@ func AuthenticateUser(kit *kit.Kit) (kit.Auth, error) {
 	err := db.Get().
 		Preload("User").
 		Find(&session, "token = ? AND expires_at > ?", token, time.Now()).Error
-	if err != nil || session.ID == 0 {
+	if err != nil || session.ID == 0 || session.User.Disabled() || twoFactorMissing(session.User) {
 		return auth, nil
 	}
 
 	return Auth{
-		LoggedIn: true,
-		UserID:   session.User.ID,
-		Email:    session.User.Email,
+		LoggedIn:    true,
+		UserID:      session.User.ID,
+		Email:       session.User.Email,
+		Role:        session.User.Role,
+		Permissions: PermissionsForRole(session.User.Role),
+		Method:      AuthMethodSession,
 	}, nil
 }
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHandleEmailVerify(t *testing.T) {
+	setupTestDB(t)
+	config.Secret = "test-secret"
+	config.Auth.EmailVerificationExpiryInHours = 1
+
+	verifyToken := func(user User) string {
+		token, err := createVerificationToken(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	emailChangeToken := func(user User) string {
+		token, err := createEmailChangeToken(user, "new@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	noAudienceToken := func(user User) string {
+		token, err := createSignedUserToken(user.ID, "", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	expiredToken := func(user User) string {
+		token, err := createSignedUserToken(user.ID, emailVerifyTokenAudience, -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		token    func(User) string
+		verified bool
+	}{
+		{"verification token", verifyToken, true},
+		{"email change token", emailChangeToken, false},
+		{"token without an audience", noAudienceToken, false},
+		{"expired token", expiredToken, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, fmt.Sprintf("user%d@example.com", i))
+			r := httptest.NewRequest("GET", "/email/verify?token="+url.QueryEscape(tt.token(user)), nil)
+			r = r.WithContext(context.WithValue(r.Context(), kit.AuthKey{}, Auth{}))
+			w := httptest.NewRecorder()
+			if err := HandleEmailVerify(&kit.Kit{Response: w, Request: r}); err != nil {
+				t.Fatal(err)
+			}
+			if redirected := w.Code == http.StatusSeeOther; redirected != tt.verified {
+				t.Errorf("got status %d, want verified %v", w.Code, tt.verified)
+			}
+			if err := db.Get().First(&user, user.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if user.EmailVerifiedAt.Valid != tt.verified {
+				t.Errorf("email verified %v, want %v", user.EmailVerifiedAt.Valid, tt.verified)
+			}
+		})
+	}
+}
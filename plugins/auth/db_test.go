@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"gothstack/app/conf"
+	"gothstack/app/db"
+	"gothstack/app/plugin"
+	"path/filepath"
+	"testing"
+)
+
+// setupTestDB points db.Get at a new in-memory database with the
+// migrations of the plugin applied, and sets the configuration to the
+// defaults the tests rely on.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	openTestDB(t, ":memory:")
+	sqlDB, err := db.Get().DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens its own database.
+	sqlDB.SetMaxOpenConns(1)
+	migrateTestDB(t)
+}
+
+// setupConcurrentTestDB is like setupTestDB, but with a database file that
+// several connections can write to at the same time.
+func setupConcurrentTestDB(t *testing.T) {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "test.db")
+	openTestDB(t, name+"?_busy_timeout=5000&_txlock=immediate")
+	migrateTestDB(t)
+}
+
+// openTestDB points db.Get at the named database with the test
+// configuration.
+func openTestDB(t *testing.T, name string) {
+	t.Helper()
+	config = &conf.Config{
+		Auth: conf.Auth{
+			LoginMaxAttempts:            3,
+			LoginMaxAttemptsPerIP:       5,
+			LoginAttemptWindowInMinutes: 60,
+			LockoutMinutes:              1,
+			LockoutMaxMinutes:           60,
+			UnlockExpiryInHours:         24,
+		},
+	}
+	if err := db.Init(conf.DB{Driver: "sqlite3", Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+}
+
+// migrateTestDB applies the migrations of the plugin to db.Get.
+func migrateTestDB(t *testing.T) {
+	t.Helper()
+	sqlDB, err := db.Get().DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plugin.Enable(config, []plugin.Plugin{Plugin{}}); err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := plugin.NewMigrator(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createTestUser inserts an unverified user with the given email.
+func createTestUser(t *testing.T, email string) User {
+	t.Helper()
+	user := User{Email: email, FirstName: "Test", LastName: "User"}
+	if err := db.Get().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
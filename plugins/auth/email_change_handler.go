@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	v "gothstack/kit/validate"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const emailChangeTokenAudience = "email-change"
+
+var emailChangeSchema = v.Schema{
+	"email": v.Rules(v.Email),
+}
+
+// emailChangeClaims are the claims of the token mailed to the new address.
+// The old email is included so the token stops working once the email
+// changed in any other way.
+type emailChangeClaims struct {
+	jwt.RegisteredClaims
+
+	NewEmail string `json:"new_email"`
+	OldEmail string `json:"old_email"`
+}
+
+// HandleEmailChangeCreate starts an email change. The email is only
+// switched once the new address is confirmed. Accounts with a password
+// confirm the change with it, accounts without one only through the link
+// sent to the new address.
+func HandleEmailChangeCreate(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	var user User
+	if err := db.Get().First(&user, auth.UserID).Error; err != nil {
+		return err
+	}
+	hasPassword := len(user.PasswordHash) > 0
+
+	values := EmailChangeFormValues{HasPassword: hasPassword}
+	errors, ok := v.Request(kit.Request, &values, emailChangeSchema)
+	if hasPassword && len(values.Password) == 0 {
+		errors.Add("password", "is a required field")
+		ok = false
+	}
+	if !ok {
+		return kit.Render(EmailChangeForm(values, errors))
+	}
+	if strings.EqualFold(values.Email, user.Email) {
+		errors.Add("email", "this is already your email")
+		return kit.Render(EmailChangeForm(values, errors))
+	}
+
+	if hasPassword {
+		lockedUntil, locked, err := loginLockedUntil(accountThrottleKey(user.Email), ipThrottleKey(clientIP(kit.Request)))
+		if err != nil {
+			return err
+		}
+		if locked {
+			errors.Add("password", lockoutMessage(lockedUntil))
+			return kit.Render(EmailChangeForm(values, errors))
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(values.Password))
+		if err != nil {
+			if err := recordLoginFailure(kit, user.Email, user); err != nil {
+				return err
+			}
+			errors.Add("password", "invalid password")
+			return kit.Render(EmailChangeForm(values, errors))
+		}
+	}
+
+	taken, err := emailTaken(values.Email, user.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		errors.Add("email", "email is already used by another account")
+		return kit.Render(EmailChangeForm(values, errors))
+	}
+
+	token, err := createEmailChangeToken(user, values.Email)
+	if err != nil {
+		return err
+	}
+	event.Emit(EmailChangeEvent, EmailChangeRequest{
+		User:     user,
+		NewEmail: values.Email,
+		Token:    token,
+	})
+	event.Emit(EmailChangeNoticeEvent, EmailChangeRequest{
+		User:     user,
+		NewEmail: values.Email,
+	})
+
+	values = EmailChangeFormValues{
+		HasPassword: hasPassword,
+		Success:     fmt.Sprintf("We sent a confirmation link to %s. Your email changes once you open it.", values.Email),
+	}
+	return kit.Render(EmailChangeForm(values, v.Errors{}))
+}
+
+// HandleEmailChangeConfirm switches the email to the confirmed new address.
+func HandleEmailChangeConfirm(kit *kit.Kit) error {
+	claims, err := parseEmailChangeToken(kit.Request.URL.Query().Get("token"))
+	if err != nil {
+		return kit.Render(EmailVerificationError("Email change link is invalid or has expired"))
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return kit.Render(EmailVerificationError("Email change link is invalid or has expired"))
+	}
+
+	var user User
+	if err := db.Get().First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.Email != claims.OldEmail {
+		return kit.Render(EmailVerificationError("Email change link is invalid or has expired"))
+	}
+	taken, err := emailTaken(claims.NewEmail, user.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return kit.Render(EmailVerificationError("Email is already used by another account"))
+	}
+
+	err = db.Get().Model(&user).Updates(map[string]any{
+		"email":             claims.NewEmail,
+		"email_verified_at": sql.NullTime{Time: time.Now(), Valid: true},
+	}).Error
+	if err != nil {
+		return err
+	}
+	recordActivity(kit, user.ID, ActivityEmailChanged, fmt.Sprintf("%s to %s", claims.OldEmail, claims.NewEmail))
+
+	if err := kit.FlashSuccess("Your email address is now " + claims.NewEmail + "."); err != nil {
+		return err
+	}
+	return kit.Redirect(http.StatusSeeOther, "/profile")
+}
+
+func createEmailChangeToken(user User, newEmail string) (string, error) {
+	claims := emailChangeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   fmt.Sprint(user.ID),
+			Audience:  jwt.ClaimStrings{emailChangeTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(config.Auth.EmailVerificationExpiryInHours))),
+		},
+		NewEmail: newEmail,
+		OldEmail: user.Email,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString([]byte(config.Secret))
+}
+
+func parseEmailChangeToken(tokenStr string) (*emailChangeClaims, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenStr, &emailChangeClaims{}, func(token *jwt.Token) (any, error) {
+			return []byte(config.Secret), nil
+		}, jwt.WithLeeway(5*time.Second), jwt.WithAudience(emailChangeTokenAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*emailChangeClaims)
+	if !ok || !token.Valid || len(claims.NewEmail) == 0 {
+		return nil, errors.New("invalid email change token")
+	}
+	return claims, nil
+}
+
+// emailTaken returns true if another user than userID uses the email.
+func emailTaken(email string, userID uint) (bool, error) {
+	var count int64
+	err := db.Get().Model(&User{}).
+		Where("lower(email) = lower(?) AND id <> ?", email, userID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHandleEmailChangeCreate(t *testing.T) {
+	setupTestDB(t)
+	config.Secret = "test-secret"
+	config.Auth.EmailVerificationExpiryInHours = 1
+
+	withPassword := createTestUser(t, "password@example.com")
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get().Model(&withPassword).Update("password_hash", string(hash)).Error; err != nil {
+		t.Fatal(err)
+	}
+	passwordless := createTestUser(t, "passwordless@example.com")
+
+	changes := make(chan EmailChangeRequest, 10)
+	sub := event.Subscribe(EmailChangeEvent, func(ctx context.Context, e any) {
+		changes <- e.(EmailChangeRequest)
+	})
+	t.Cleanup(func() { event.Unsubscribe(sub) })
+
+	tests := []struct {
+		name     string
+		user     User
+		password string
+		sent     bool
+		failures int
+	}{
+		{"correct password", withPassword, "correct horse", true, 0},
+		{"wrong password", withPassword, "wrong", false, 1},
+		{"missing password", withPassword, "", false, 0},
+		{"without a password", passwordless, "", true, 0},
+		{"without a password, any password is ignored", passwordless, "anything", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := clearLoginFailures(accountThrottleKey(tt.user.Email)); err != nil {
+				t.Fatal(err)
+			}
+			form := url.Values{"email": {"new@example.com"}, "password": {tt.password}}
+			r := httptest.NewRequest("POST", "/profile/email", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r = r.WithContext(context.WithValue(r.Context(), kit.AuthKey{}, Auth{LoggedIn: true, UserID: tt.user.ID}))
+			if err := HandleEmailChangeCreate(&kit.Kit{Response: httptest.NewRecorder(), Request: r}); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case change := <-changes:
+				if !tt.sent {
+					t.Error("a confirmation link was sent")
+				} else if change.User.ID != tt.user.ID || change.NewEmail != "new@example.com" || len(change.Token) == 0 {
+					t.Errorf("got change of user %d to %q with token %q", change.User.ID, change.NewEmail, change.Token)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.sent {
+					t.Error("no confirmation link was sent")
+				}
+			}
+
+			var attempt LoginAttempt
+			err := db.Get().Where("throttle_key = ?", accountThrottleKey(tt.user.Email)).Find(&attempt).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+			if attempt.Failures != tt.failures {
+				t.Errorf("got %d login failures, want %d", attempt.Failures, tt.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	v "gothstack/kit/validate"
+	"net/url"
+)
+
+var inviteSchema = v.Schema{
+	"firstName": v.Rules(v.Max(50)),
+	"lastName":  v.Rules(v.Max(50)),
+	"phone":     v.Rules(v.Max(20)),
+}
+
+var inviteEmailSchema = v.Schema{
+	"email": v.Rules(v.Email),
+}
+
+// HandleInviteIndex shows the invite form and the latest invites.
+func HandleInviteIndex(kit *kit.Kit) error {
+	invites, err := recentInvites()
+	if err != nil {
+		return err
+	}
+	return kit.Render(InviteIndex(invites, inviteOnly()))
+}
+
+// HandleInviteCreate creates an invite. Invites tied to an email are
+// mailed, the code and link of every invite are shown once so they can
+// also be handed over in person.
+func HandleInviteCreate(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	var values InviteFormValues
+	errors, ok := v.Request(kit.Request, &values, inviteSchema)
+	if len(values.Email) > 0 {
+		if emailErrors, valid := v.Validate(&values, inviteEmailSchema); !valid {
+			errors["email"] = emailErrors["email"]
+			ok = false
+		}
+	}
+	if !ok {
+		return kit.Render(InviteForm(values, errors))
+	}
+
+	if len(values.Email) > 0 {
+		taken, err := emailTaken(values.Email, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			errors.Add("email", "an account with this email already exists")
+			return kit.Render(InviteForm(values, errors))
+		}
+	}
+
+	phone := normalizePhone(values.Phone)
+	if len(phone) > 0 {
+		var count int64
+		if err := db.Get().Model(&User{}).Where("phone = ?", phone).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			errors.Add("phone", "phone number is already used by another account")
+			return kit.Render(InviteForm(values, errors))
+		}
+	}
+
+	invite := Invite{
+		Email:       values.Email,
+		FirstName:   values.FirstName,
+		LastName:    values.LastName,
+		Phone:       phone,
+		CreatedByID: &auth.UserID,
+	}
+	code, err := createInvite(&invite)
+	if err != nil {
+		return err
+	}
+	if len(invite.Email) > 0 {
+		event.Emit(InviteCreatedEvent, InviteWithCode{
+			Invite: invite,
+			Code:   code,
+		})
+	}
+
+	values = InviteFormValues{
+		Code: code,
+		Link: appURL(kit.Request) + "/signup?invite=" + url.QueryEscape(code),
+	}
+	return kit.Render(InviteForm(values, v.Errors{}))
+}
+
+// HandleInviteDelete revokes an invite that has not been used yet.
+func HandleInviteDelete(kit *kit.Kit) error {
+	id, err := kit.URLParamID("id")
+	if err != nil {
+		return err
+	}
+	err = db.Get().
+		Where("id = ? AND used_at IS NULL", id).
+		Delete(&Invite{}).Error
+	if err != nil {
+		return err
+	}
+
+	invites, err := recentInvites()
+	if err != nil {
+		return err
+	}
+	return kit.Render(InviteList(invites))
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"gothstack/app/db"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Signup modes selected with SUPERKIT_AUTH_SIGNUP_MODE.
+const (
+	SignupModeOpen   = "open"
+	SignupModeInvite = "invite"
+)
+
+// inviteCodeAlphabet leaves out characters that are easily confused when
+// a code is read out or typed from paper.
+const inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var errInvalidInvite = errors.New("invalid, used or expired invite")
+
+// Invite lets one person sign up when signup is invite only. The email
+// and name are used to pre-fill the signup form. If an email is set, the
+// invite can only be used to sign up with that email. Only the SHA-256
+// hash of the code is stored.
+type Invite struct {
+	gorm.Model
+
+	CodeHash    string `json:"-"`
+	Email       string
+	FirstName   string
+	LastName    string
+	Phone       string
+	ExpiresAt   time.Time
+	UsedAt      sql.NullTime
+	UsedByID    *uint
+	CreatedByID *uint
+	UsedBy      *User
+	CreatedBy   *User
+}
+
+// Usable returns true if the invite was not used and has not expired.
+func (invite Invite) Usable() bool {
+	return !invite.UsedAt.Valid && invite.ExpiresAt.After(time.Now())
+}
+
+// inviteOnly returns true if new accounts can only be created with an invite.
+func inviteOnly() bool {
+	return config.Auth.SignupMode == SignupModeInvite
+}
+
+// createInvite stores the invite and returns its code in plain text.
+func createInvite(invite *Invite) (string, error) {
+	code, err := newInviteCode()
+	if err != nil {
+		return "", err
+	}
+	invite.CodeHash = hashToken(normalizeInviteCode(code))
+	invite.ExpiresAt = time.Now().Add(inviteExpiry())
+	return code, db.Get().Create(invite).Error
+}
+
+// findInvite returns the usable invite matching the code.
+func findInvite(tx *gorm.DB, code string) (Invite, error) {
+	var invite Invite
+	err := tx.Where("code_hash = ? AND used_at IS NULL AND expires_at > ?",
+		hashToken(normalizeInviteCode(code)), time.Now()).
+		First(&invite).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return invite, errInvalidInvite
+	}
+	return invite, err
+}
+
+// useInvite marks the invite as used by the given user inside the given
+// transaction. An invite can only be used once.
+func useInvite(tx *gorm.DB, invite Invite, userID uint) error {
+	result := tx.Model(&Invite{}).
+		Where("id = ? AND used_at IS NULL", invite.ID).
+		Updates(map[string]any{
+			"used_at":    time.Now(),
+			"used_by_id": userID,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidInvite
+	}
+	return nil
+}
+
+// recentInvites returns the latest invites, newest first.
+func recentInvites() ([]Invite, error) {
+	var invites []Invite
+	err := db.Get().
+		Preload("CreatedBy").
+		Preload("UsedBy").
+		Order("created_at desc").
+		Limit(100).
+		Find(&invites).Error
+	return invites, err
+}
+
+// newInviteCode returns a random code formatted as XXXX-XXXX-XXXX.
+func newInviteCode() (string, error) {
+	var b strings.Builder
+	size := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := 0; i < 12; i++ {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(inviteCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeInviteCode makes codes typed with lowercase letters, spaces or
+// without dashes match.
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+}
+
+func inviteExpiry() time.Duration {
+	return 24 * time.Hour * time.Duration(config.Auth.InviteExpiryInDays)
+}
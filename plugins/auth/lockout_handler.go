@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"gothstack/kit"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HandleLockoutIndex lists the most recent login lockouts for admins.
+func HandleLockoutIndex(kit *kit.Kit) error {
+	lockouts, err := recentLockouts()
+	if err != nil {
+		return err
+	}
+	return kit.Render(LockoutIndex(lockouts))
+}
+
+// HandleLockoutDelete lets an admin lift a lockout before it expires.
+func HandleLockoutDelete(kit *kit.Kit) error {
+	id, err := kit.URLParamID("id")
+	if err != nil {
+		return err
+	}
+
+	var lockout AccountLockout
+	if err := db.Get().First(&lockout, id).Error; err != nil {
+		return err
+	}
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&AccountLockout{}).
+			Where("throttle_key = ? AND unlocked_at IS NULL", lockout.Key).
+			Update("unlocked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().
+			Where("throttle_key = ?", lockout.Key).
+			Delete(&LoginAttempt{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	lockouts, err := recentLockouts()
+	if err != nil {
+		return err
+	}
+	return kit.Render(LockoutList(lockouts))
+}
+
+func recentLockouts() ([]AccountLockout, error) {
+	var lockouts []AccountLockout
+	err := db.Get().
+		Preload("User").
+		Order("created_at desc").
+		Limit(100).
+		Find(&lockouts).Error
+	return lockouts, err
+}
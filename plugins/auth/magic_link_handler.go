@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	v "gothstack/kit/validate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var magicLinkSchema = v.Schema{
+	"email": v.Rules(v.Email),
+}
+
+func HandleMagicLinkIndex(kit *kit.Kit) error {
+	return kit.Render(MagicLinkIndex(MagicLinkFormValues{}))
+}
+
+// HandleMagicLinkCreate mails a one-time login link to the given address.
+func HandleMagicLinkCreate(kit *kit.Kit) error {
+	var values MagicLinkFormValues
+	errors, ok := v.Request(kit.Request, &values, magicLinkSchema)
+	if !ok {
+		return kit.Render(MagicLinkForm(values, errors))
+	}
+
+	// Never tell the client whether an account exists for the given email.
+	var user User
+	err := db.Get().Find(&user, "email = ?", values.Email).Error
+	if err != nil {
+		return err
+	}
+	if user.ID > 0 {
+		token, err := createUserToken(user.ID, TokenPurposeMagicLink, magicLinkExpiry())
+		if err != nil {
+			return err
+		}
+		event.Emit(MagicLinkEvent, UserWithLoginToken{
+			User:  user,
+			Token: token,
+		})
+	}
+
+	return kit.Render(MagicLinkSent(values.Email))
+}
+
+// HandleMagicLinkVerifyIndex asks the user to confirm the login. The token
+// is only consumed by the POST so link previews and mail scanners opening
+// the link don't use it up.
+func HandleMagicLinkVerifyIndex(kit *kit.Kit) error {
+	token := kit.Request.URL.Query().Get("token")
+	if _, err := findUserToken(db.Get(), TokenPurposeMagicLink, token); err != nil {
+		if errors.Is(err, errInvalidToken) {
+			return kit.Render(EmailVerificationError("Login link is invalid or has expired"))
+		}
+		return err
+	}
+	return kit.Render(MagicLinkConfirm(token))
+}
+
+// HandleMagicLinkVerifyCreate consumes the token and logs the user in.
+func HandleMagicLinkVerifyCreate(kit *kit.Kit) error {
+	var user User
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, TokenPurposeMagicLink, kit.FormValue("token"))
+		if err != nil {
+			return err
+		}
+		user = userToken.User
+		// Following the link proves the user owns the email address.
+		if !user.EmailVerifiedAt.Valid {
+			user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			return tx.Model(&user).Update("email_verified_at", user.EmailVerifiedAt).Error
+		}
+		return nil
+	})
+	if errors.Is(err, errInvalidToken) {
+		return kit.Render(EmailVerificationError("Login link is invalid or has expired"))
+	}
+	if err != nil {
+		return err
+	}
+
+	return completeLogin(kit, user)
+}
+
+func magicLinkExpiry() time.Duration {
+	return time.Minute * time.Duration(config.Auth.MagicLinkExpiryInMinutes)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"embed"
+	"gothstack/app/views/emails"
+	"time"
+)
+
+//go:embed mail/*.txt
+var mailFS embed.FS
+
+// mailer sends the emails of the plugin, set by Init.
+var mailer *emails.Mailer
+
+// linkMailData is the data of the plain text emails that greet the user
+// and contain a single link.
+type linkMailData struct {
+	Name        string
+	Link        string
+	NewEmail    string
+	LockedUntil time.Time
+}
+
+func onUserSignup(ctx context.Context, event any) {
+	userWithToken, ok := event.(UserWithVerificationToken)
+	if !ok {
+		return
+	}
+	sendVerificationEmail(ctx, userWithToken)
+}
+
+func onResendVerificationToken(ctx context.Context, event any) {
+	userWithToken, ok := event.(UserWithVerificationToken)
+	if !ok {
+		return
+	}
+	sendVerificationEmail(ctx, userWithToken)
+}
+
+func sendVerificationEmail(ctx context.Context, userWithToken UserWithVerificationToken) {
+	user := userWithToken.User
+	data := linkMailData{
+		Name: user.FirstName,
+		Link: mailer.Link("/email/verify", "token", userWithToken.Token),
+	}
+	mailer.Send(ctx, user.Email, "Confirm your email address",
+		VerifyEmailMail(data.Name, data.Link), "verify_email.txt", data)
+}
+
+func onPasswordReset(ctx context.Context, event any) {
+	userWithToken, ok := event.(UserWithResetToken)
+	if !ok {
+		return
+	}
+	user := userWithToken.User
+	data := linkMailData{
+		Name: user.FirstName,
+		Link: mailer.Link("/password/reset", "token", userWithToken.Token),
+	}
+	mailer.Send(ctx, user.Email, "Reset your password",
+		PasswordResetMail(data.Name, data.Link), "password_reset.txt", data)
+}
+
+func onAccountLocked(ctx context.Context, event any) {
+	userWithToken, ok := event.(UserWithUnlockToken)
+	if !ok {
+		return
+	}
+	user := userWithToken.User
+	data := linkMailData{
+		Name:        user.FirstName,
+		Link:        mailer.Link("/account/unlock", "token", userWithToken.Token),
+		LockedUntil: userWithToken.LockedUntil,
+	}
+	mailer.Send(ctx, user.Email, "Your account was locked",
+		AccountLockedMail(data.Name, data.Link, data.LockedUntil), "account_locked.txt", data)
+}
+
+func onMagicLink(ctx context.Context, event any) {
+	userWithToken, ok := event.(UserWithLoginToken)
+	if !ok {
+		return
+	}
+	user := userWithToken.User
+	data := linkMailData{
+		Name: user.FirstName,
+		Link: mailer.Link("/login/magic/verify", "token", userWithToken.Token),
+	}
+	mailer.Send(ctx, user.Email, "Your sign in link",
+		MagicLinkMail(data.Name, data.Link), "magic_link.txt", data)
+}
+
+func onEmailChange(ctx context.Context, event any) {
+	request, ok := event.(EmailChangeRequest)
+	if !ok {
+		return
+	}
+	data := linkMailData{
+		Name:     request.User.FirstName,
+		Link:     mailer.Link("/email/change/confirm", "token", request.Token),
+		NewEmail: request.NewEmail,
+	}
+	mailer.Send(ctx, request.NewEmail, "Confirm your new email address",
+		EmailChangeMail(data.Name, data.NewEmail, data.Link), "email_change.txt", data)
+}
+
+func onEmailChangeNotice(ctx context.Context, event any) {
+	request, ok := event.(EmailChangeRequest)
+	if !ok {
+		return
+	}
+	data := linkMailData{
+		Name:     request.User.FirstName,
+		NewEmail: request.NewEmail,
+	}
+	mailer.Send(ctx, request.User.Email, "Your email address is being changed",
+		EmailChangeNoticeMail(data.Name, data.NewEmail), "email_change_notice.txt", data)
+}
+
+func onAccountDeleted(ctx context.Context, event any) {
+	user, ok := event.(User)
+	if !ok {
+		return
+	}
+	data := linkMailData{Name: user.FirstName}
+	mailer.Send(ctx, user.Email, "Your account was deleted",
+		AccountDeletedMail(data.Name), "account_deleted.txt", data)
+}
+
+func onInviteCreated(ctx context.Context, event any) {
+	inviteWithCode, ok := event.(InviteWithCode)
+	if !ok {
+		return
+	}
+	invite := inviteWithCode.Invite
+	// Invites without an address are handed out by staff in person.
+	if len(invite.Email) == 0 {
+		return
+	}
+	data := struct {
+		Code      string
+		Link      string
+		ExpiresAt time.Time
+	}{
+		Code:      inviteWithCode.Code,
+		Link:      mailer.Link("/signup", "invite", inviteWithCode.Code),
+		ExpiresAt: invite.ExpiresAt,
+	}
+	mailer.Send(ctx, invite.Email, "You're invited",
+		InviteMail(data.Code, data.Link, data.ExpiresAt), "invite.txt", data)
+}
@@ -0,0 +1,21 @@
+-- +goose Up
+alter table users add column totp_secret text;
+alter table users add column totp_enabled_at datetime;
+alter table users add column totp_last_step integer not null default 0;
+
+create table if not exists recovery_codes(
+	id integer primary key,
+	user_id integer not null references users(id) on delete cascade,
+	code_hash text not null,
+	used_at datetime,
+	created_at datetime not null,
+	updated_at datetime not null,
+	deleted_at datetime
+);
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+-- +goose Down
+drop table if exists recovery_codes;
+alter table users drop column totp_last_step;
+alter table users drop column totp_enabled_at;
+alter table users drop column totp_secret;
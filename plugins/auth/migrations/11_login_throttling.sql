@@ -0,0 +1,30 @@
+-- +goose Up
+create table if not exists login_attempts(
+	id integer primary key,
+	throttle_key text not null,
+	failures integer not null default 0,
+	locked_until datetime,
+	created_at datetime not null,
+	updated_at datetime not null,
+	deleted_at datetime
+);
+CREATE UNIQUE INDEX idx_login_attempts_throttle_key ON login_attempts(throttle_key);
+
+create table if not exists account_lockouts(
+	id integer primary key,
+	throttle_key text not null,
+	user_id integer references users(id) on delete cascade,
+	ip_address text,
+	failures integer not null default 0,
+	locked_until datetime not null,
+	unlocked_at datetime,
+	created_at datetime not null,
+	updated_at datetime not null,
+	deleted_at datetime
+);
+CREATE INDEX idx_account_lockouts_throttle_key ON account_lockouts(throttle_key);
+CREATE INDEX idx_account_lockouts_user_id ON account_lockouts(user_id);
+
+-- +goose Down
+drop table if exists account_lockouts;
+drop table if exists login_attempts;
@@ -0,0 +1,16 @@
+-- +goose Up
+create table if not exists user_identities(
+	id integer primary key,
+	user_id integer not null references users(id) on delete cascade,
+	provider text not null,
+	subject text not null,
+	email text,
+	created_at datetime not null,
+	updated_at datetime not null,
+	deleted_at datetime
+);
+CREATE UNIQUE INDEX idx_user_identities_provider_subject ON user_identities(provider, subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- +goose Down
+drop table if exists user_identities;
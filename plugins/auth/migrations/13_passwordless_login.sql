@@ -0,0 +1,9 @@
+-- +goose Up
+alter table users add column phone text;
+alter table users add column pin_hash text;
+CREATE UNIQUE INDEX idx_users_phone ON users(phone) WHERE phone IS NOT NULL AND phone <> '';
+
+-- +goose Down
+drop index if exists idx_users_phone;
+alter table users drop column pin_hash;
+alter table users drop column phone;
@@ -0,0 +1,19 @@
+-- +goose Up
+alter table users add column disabled_at datetime;
+
+create table if not exists user_activities(
+	id integer primary key,
+	user_id integer not null references users(id) on delete cascade,
+	actor_id integer references users(id) on delete set null,
+	action text not null,
+	detail text not null default '',
+	ip_address text,
+	created_at datetime not null,
+	updated_at datetime not null,
+	deleted_at datetime
+);
+CREATE INDEX idx_user_activities_user_id ON user_activities(user_id, created_at);
+
+-- +goose Down
+drop table if exists user_activities;
+alter table users drop column disabled_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+create table if not exists invites(
+	id integer primary key,
+	code_hash text not null,
+	email text not null default '',
+	first_name text not null default '',
+	last_name text not null default '',
+	phone text not null default '',
+	expires_at datetime not null,
+	used_at datetime,
+	used_by_id integer references users(id) on delete set null,
+	created_by_id integer references users(id) on delete set null,
+	created_at datetime not null,
+	updated_at datetime not null,
+	deleted_at datetime
+);
+CREATE UNIQUE INDEX idx_invites_code_hash ON invites(code_hash);
+
+-- +goose Down
+drop table if exists invites;
@@ -0,0 +1,17 @@
+-- +goose Up
+create table if not exists user_tokens(
+	id integer primary key,
+	user_id integer not null references users(id) on delete cascade,
+	purpose text not null,
+	token_hash text not null,
+	expires_at datetime not null,
+	used_at datetime,
+	created_at datetime not null,
+	updated_at datetime not null,
+	deleted_at datetime
+);
+CREATE UNIQUE INDEX idx_user_tokens_token_hash ON user_tokens(token_hash);
+CREATE INDEX idx_user_tokens_user_id ON user_tokens(user_id);
+
+-- +goose Down
+drop table if exists user_tokens;
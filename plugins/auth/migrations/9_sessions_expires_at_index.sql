@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sessions_expires_at;
@@ -0,0 +1,450 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProvider is an external identity provider configured from the
+// environment. Providers are listed in SUPERKIT_AUTH_OIDC_PROVIDERS and each
+// one is configured with SUPERKIT_AUTH_OIDC_<NAME>_* variables:
+//
+//	ISSUER         issuer URL used for OpenID Connect discovery
+//	CLIENT_ID      client ID registered at the provider
+//	CLIENT_SECRET  client secret, empty for public clients
+//	SCOPES         requested scopes, defaults to "openid email profile"
+//	LABEL          name shown on the login button
+//
+// Plain OAuth2 providers without discovery set AUTH_URL, TOKEN_URL and
+// USERINFO_URL instead of ISSUER.
+type OIDCProvider struct {
+	Name         string
+	Label        string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	JWKSURL      string
+}
+
+// ExternalIdentity holds the claims about the user returned by a provider.
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Name          string `json:"name"`
+}
+
+var (
+	oidcClient = &http.Client{Timeout: 10 * time.Second}
+
+	discoveryMu    sync.Mutex
+	discoveryCache = map[string]cachedDiscovery{}
+
+	jwksMu    sync.Mutex
+	jwksCache = map[string]cachedJWKS{}
+)
+
+const (
+	discoveryCacheTTL = time.Hour
+	jwksRefreshDelay  = time.Minute
+)
+
+type cachedDiscovery struct {
+	discovery oidcDiscovery
+	fetchedAt time.Time
+}
+
+type cachedJWKS struct {
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// oidcProviders returns all providers listed in SUPERKIT_AUTH_OIDC_PROVIDERS.
+func oidcProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, provider := range config.Auth.OIDC {
+		providers = append(providers, OIDCProvider{
+			Name:         provider.Name,
+			Label:        provider.Label,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			Scopes:       provider.Scopes,
+			AuthURL:      provider.AuthURL,
+			TokenURL:     provider.TokenURL,
+			UserInfoURL:  provider.UserInfoURL,
+		})
+	}
+	return providers
+}
+
+// findOIDCProvider returns the configured provider with the given name,
+// with its endpoints resolved through discovery if it has an issuer.
+func findOIDCProvider(ctx context.Context, name string) (OIDCProvider, bool, error) {
+	for _, provider := range oidcProviders() {
+		if provider.Name != name {
+			continue
+		}
+		if len(provider.Issuer) == 0 {
+			return provider, true, nil
+		}
+		discovery, err := discover(ctx, provider.Issuer)
+		if err != nil {
+			return provider, true, err
+		}
+		provider.AuthURL = discovery.AuthorizationEndpoint
+		provider.TokenURL = discovery.TokenEndpoint
+		provider.UserInfoURL = discovery.UserInfoEndpoint
+		provider.JWKSURL = discovery.JWKSURI
+		return provider, true, nil
+	}
+	return OIDCProvider{}, false, nil
+}
+
+// authCodeURL returns the authorization URL the user is redirected to.
+func (provider OIDCProvider) authCodeURL(redirectURI, state, nonce, verifier string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", provider.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("scope", provider.Scopes)
+	params.Set("state", state)
+	params.Set("code_challenge", pkceChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+	if len(provider.Issuer) > 0 {
+		params.Set("nonce", nonce)
+	}
+	sep := "?"
+	if strings.Contains(provider.AuthURL, "?") {
+		sep = "&"
+	}
+	return provider.AuthURL + sep + params.Encode()
+}
+
+// exchange trades the authorization code for the identity of the user.
+func (provider OIDCProvider) exchange(ctx context.Context, code, redirectURI, verifier, nonce string) (ExternalIdentity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", provider.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(provider.ClientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+
+	var token oidcTokenResponse
+	if err := fetchJSON(req, &token); err != nil {
+		return ExternalIdentity{}, fmt.Errorf("token exchange: %w", err)
+	}
+
+	var identity ExternalIdentity
+	if len(token.IDToken) > 0 {
+		identity, err = provider.verifyIDToken(ctx, token.IDToken, nonce)
+		if err != nil {
+			return identity, err
+		}
+	} else if len(provider.Issuer) > 0 {
+		return identity, errors.New("provider did not return an id_token")
+	}
+	if len(identity.Email) == 0 && len(provider.UserInfoURL) > 0 {
+		info, err := provider.userInfo(ctx, token.AccessToken)
+		if err != nil {
+			return identity, err
+		}
+		// The subject of the ID token is authoritative when there is one.
+		if len(identity.Subject) > 0 && info.Subject != identity.Subject {
+			return identity, errors.New("userinfo subject does not match id_token")
+		}
+		identity = info
+	}
+	if len(identity.Subject) == 0 {
+		return identity, errors.New("provider did not return a subject")
+	}
+	return identity, nil
+}
+
+// verifyIDToken validates the signature, issuer, audience, expiry and nonce
+// of the ID token and returns its claims.
+func (provider OIDCProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (ExternalIdentity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return lookupJWK(ctx, provider.JWKSURL, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(provider.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return ExternalIdentity{}, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return ExternalIdentity{}, errors.New("invalid id_token: nonce mismatch")
+	}
+
+	identity := ExternalIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}
+	if len(identity.FirstName) == 0 {
+		identity.FirstName, identity.LastName, _ = strings.Cut(claims.Name, " ")
+	}
+	return identity, nil
+}
+
+// userInfo fetches the identity from the userinfo endpoint. Besides the
+// standard OpenID claims it understands the "id" field used by many plain
+// OAuth2 providers.
+func (provider OIDCProvider) userInfo(ctx context.Context, accessToken string) (ExternalIdentity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.UserInfoURL, nil)
+	if err != nil {
+		return ExternalIdentity{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info map[string]any
+	if err := fetchJSON(req, &info); err != nil {
+		return ExternalIdentity{}, fmt.Errorf("userinfo: %w", err)
+	}
+	identity := ExternalIdentity{
+		EmailVerified: isTrue(info["email_verified"]),
+	}
+	identity.Subject, _ = info["sub"].(string)
+	if len(identity.Subject) == 0 && info["id"] != nil {
+		identity.Subject = fmt.Sprint(info["id"])
+	}
+	identity.Email, _ = info["email"].(string)
+	identity.FirstName, _ = info["given_name"].(string)
+	identity.LastName, _ = info["family_name"].(string)
+	if len(identity.FirstName) == 0 {
+		name, _ := info["name"].(string)
+		identity.FirstName, identity.LastName, _ = strings.Cut(name, " ")
+	}
+	return identity, nil
+}
+
+func discover(ctx context.Context, issuer string) (oidcDiscovery, error) {
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+	if cached, ok := discoveryCache[issuer]; ok && time.Since(cached.fetchedAt) < discoveryCacheTTL {
+		return cached.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return oidcDiscovery{}, err
+	}
+	var discovery oidcDiscovery
+	if err := fetchJSON(req, &discovery); err != nil {
+		return discovery, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return discovery, fmt.Errorf("discovery: issuer %q does not match %q", discovery.Issuer, issuer)
+	}
+	discoveryCache[issuer] = cachedDiscovery{discovery: discovery, fetchedAt: time.Now()}
+	return discovery, nil
+}
+
+// lookupJWK returns the public key with the given key ID. The key set is
+// fetched again when the key is unknown, which happens after the provider
+// rotated its keys.
+func lookupJWK(ctx context.Context, jwksURL, kid string) (any, error) {
+	jwksMu.Lock()
+	defer jwksMu.Unlock()
+	cached, ok := jwksCache[jwksURL]
+	if ok {
+		if key, ok := cached.keys[kid]; ok {
+			return key, nil
+		}
+		if time.Since(cached.fetchedAt) < jwksRefreshDelay {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := fetchJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		if len(jwk.Use) > 0 && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	jwksCache[jwksURL] = cachedJWKS{keys: keys, fetchedAt: time.Now()}
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (jwk jsonWebKey) publicKey() (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+func fetchJSON(req *http.Request, v any) error {
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d: %s", req.URL.Redacted(), resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// randomString returns a URL safe random string used for the state, nonce
+// and PKCE verifier.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// isTrue handles providers that send boolean claims as strings.
+func isTrue(value any) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
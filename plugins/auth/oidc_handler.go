@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcProviderKey  = "oidcProvider"
+	oidcStateKey     = "oidcState"
+	oidcNonceKey     = "oidcNonce"
+	oidcVerifierKey  = "oidcVerifier"
+	oidcExpiresAtKey = "oidcExpiresAt"
+	oidcLoginExpiry  = 10 * time.Minute
+)
+
+var (
+	errIdentityEmailTaken = errors.New("an account with this email already exists")
+	errIdentityNoEmail    = errors.New("provider did not return an email address")
+	errIdentityLinked     = errors.New("identity is linked to another account")
+	errIdentityNoAccount  = errors.New("no account exists and signup is invite only")
+)
+
+// UserIdentity links an account at an external provider to a user.
+type UserIdentity struct {
+	gorm.Model
+
+	UserID   uint
+	Provider string
+	Subject  string
+	Email    string
+	User     User
+}
+
+// HandleOIDCLogin redirects the user to the provider to sign in. It is
+// also used to connect a provider to the account of a logged in user.
+func HandleOIDCLogin(kit *kit.Kit) error {
+	name := chi.URLParam(kit.Request, "provider")
+	provider, ok, err := findOIDCProvider(kit.Request.Context(), name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return kit.Render(EmailVerificationError("Unknown login provider"))
+	}
+
+	var values [3]string
+	for i := range values {
+		if values[i], err = randomString(); err != nil {
+			return err
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	sess := kit.GetSession(userSessionName)
+	sess.Values[oidcProviderKey] = provider.Name
+	sess.Values[oidcStateKey] = state
+	sess.Values[oidcNonceKey] = nonce
+	sess.Values[oidcVerifierKey] = verifier
+	sess.Values[oidcExpiresAtKey] = time.Now().Add(oidcLoginExpiry).Unix()
+	if err := sess.Save(kit.Request, kit.Response); err != nil {
+		return err
+	}
+
+	redirectURI := oidcRedirectURI(kit, provider.Name)
+	return kit.Redirect(http.StatusSeeOther, provider.authCodeURL(redirectURI, state, nonce, verifier))
+}
+
+// HandleOIDCCallback completes the authorization code flow, links the
+// external identity to a user and logs them in.
+func HandleOIDCCallback(kit *kit.Kit) error {
+	name := chi.URLParam(kit.Request, "provider")
+	sess := kit.GetSession(userSessionName)
+	providerName, _ := sess.Values[oidcProviderKey].(string)
+	state, _ := sess.Values[oidcStateKey].(string)
+	nonce, _ := sess.Values[oidcNonceKey].(string)
+	verifier, _ := sess.Values[oidcVerifierKey].(string)
+	expiresAt, _ := sess.Values[oidcExpiresAtKey].(int64)
+	for _, key := range []string{oidcProviderKey, oidcStateKey, oidcNonceKey, oidcVerifierKey, oidcExpiresAtKey} {
+		delete(sess.Values, key)
+	}
+	if err := sess.Save(kit.Request, kit.Response); err != nil {
+		return err
+	}
+
+	query := kit.Request.URL.Query()
+	if len(query.Get("error")) > 0 {
+		return kit.Render(EmailVerificationError("Sign in was cancelled or denied by the provider"))
+	}
+	if len(state) == 0 || providerName != name || time.Now().Unix() > expiresAt ||
+		subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		return kit.Render(EmailVerificationError("Sign in request is invalid or has expired, please try again"))
+	}
+
+	provider, ok, err := findOIDCProvider(kit.Request.Context(), name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return kit.Render(EmailVerificationError("Unknown login provider"))
+	}
+	identity, err := provider.exchange(kit.Request.Context(), query.Get("code"), oidcRedirectURI(kit, name), verifier, nonce)
+	if err != nil {
+		slog.Error("oidc sign in failed", "provider", name, "err", err)
+		return kit.Render(EmailVerificationError(fmt.Sprintf("Could not sign you in with %s", provider.Label)))
+	}
+
+	linking := kit.Auth().Check()
+	user, err := userForIdentity(kit, provider.Name, identity)
+	switch {
+	case errors.Is(err, errIdentityEmailTaken):
+		return kit.Render(EmailVerificationError("An account with this email already exists. Log in with your password and connect the provider from your profile."))
+	case errors.Is(err, errIdentityNoEmail):
+		return kit.Render(EmailVerificationError(fmt.Sprintf("%s did not share your email address", provider.Label)))
+	case errors.Is(err, errIdentityNoAccount):
+		return kit.Render(EmailVerificationError("There is no account for this email. Sign up with your invite first, then connect the provider from your profile."))
+	case errors.Is(err, errIdentityLinked):
+		return kit.Render(EmailVerificationError(fmt.Sprintf("This %s account is already connected to another user", provider.Label)))
+	case err != nil:
+		return err
+	}
+	if linking {
+		if err := kit.FlashSuccess(provider.Label + " is now connected to your account."); err != nil {
+			return err
+		}
+		return kit.Redirect(http.StatusSeeOther, "/profile")
+	}
+
+	return completeLogin(kit, user)
+}
+
+// HandleIdentityDelete disconnects a provider from the authenticated user.
+// The last sign in method of an account without a password can't be removed.
+func HandleIdentityDelete(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	id, err := kit.URLParamID("id")
+	if err != nil {
+		return err
+	}
+
+	var user User
+	if err := db.Get().First(&user, auth.UserID).Error; err != nil {
+		return err
+	}
+	errors := v.Errors{}
+	var count int64
+	if err := db.Get().Model(&UserIdentity{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if len(user.PasswordHash) == 0 && count <= 1 {
+		errors.Add("identities", "set a password before disconnecting your only sign in method")
+	} else {
+		err := db.Get().Unscoped().
+			Where("id = ? AND user_id = ?", id, user.ID).
+			Delete(&UserIdentity{}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	accounts, err := connectedAccounts(user.ID)
+	if err != nil {
+		return err
+	}
+	return kit.Render(ConnectedAccountList(accounts, errors))
+}
+
+// userForIdentity returns the user linked to the external identity. The
+// identity is linked to the logged in user, to an existing user with the
+// same verified email, or to a newly created user.
+func userForIdentity(kit *kit.Kit, provider string, identity ExternalIdentity) (User, error) {
+	auth, _ := kit.Auth().(Auth)
+
+	var existing UserIdentity
+	err := db.Get().Preload("User").
+		Where("provider = ? AND subject = ?", provider, identity.Subject).
+		First(&existing).Error
+	if err == nil {
+		if auth.Check() && auth.UserID != existing.UserID {
+			return User{}, errIdentityLinked
+		}
+		return existing.User, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, err
+	}
+
+	link := UserIdentity{
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	var user User
+	if auth.Check() {
+		if err := db.Get().First(&user, auth.UserID).Error; err != nil {
+			return user, err
+		}
+		link.UserID = user.ID
+		return user, db.Get().Create(&link).Error
+	}
+
+	if len(identity.Email) == 0 {
+		return user, errIdentityNoEmail
+	}
+	err = db.Get().First(&user, "email = ?", identity.Email).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+	if err == nil && !identity.EmailVerified {
+		return user, errIdentityEmailTaken
+	}
+	if user.ID == 0 && inviteOnly() {
+		return user, errIdentityNoAccount
+	}
+
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		if user.ID == 0 {
+			user = User{
+				Email:     identity.Email,
+				FirstName: identity.FirstName,
+				LastName:  identity.LastName,
+				Role:      RoleUser,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		}
+		if identity.EmailVerified && !user.EmailVerifiedAt.Valid {
+			user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			if err := tx.Model(&user).Update("email_verified_at", user.EmailVerifiedAt).Error; err != nil {
+				return err
+			}
+		}
+		link.UserID = user.ID
+		return tx.Create(&link).Error
+	})
+	return user, err
+}
+
+// connectedAccounts returns every configured provider together with the
+// identity the user linked at that provider, if any.
+func connectedAccounts(userID uint) ([]ConnectedAccount, error) {
+	var identities []UserIdentity
+	if err := db.Get().Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	var accounts []ConnectedAccount
+	for _, provider := range oidcProviders() {
+		account := ConnectedAccount{Provider: provider}
+		for _, identity := range identities {
+			if identity.Provider == provider.Name {
+				account.Identity = &identity
+				break
+			}
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func oidcRedirectURI(kit *kit.Kit, provider string) string {
+	return appURL(kit.Request) + "/login/oidc/" + provider + "/callback"
+}
+
+// appURL returns the public URL of the application from SUPERKIT_APP_URL,
+// falling back to the host of the request.
+func appURL(r *http.Request) string {
+	if len(config.AppURL) > 0 {
+		return config.BaseURL()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
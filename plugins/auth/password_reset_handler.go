@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"errors"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	v "gothstack/kit/validate"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var forgotPasswordSchema = v.Schema{
+	"email": v.Rules(v.Email),
+}
+
+var resetPasswordSchema = v.Schema{
+	"token":           v.Rules(v.Required),
+	"password":        passwordRules,
+	"passwordConfirm": v.Rules(v.EqualTo("password").Message("passwords do not match")),
+}
+
+func HandlePasswordForgotIndex(kit *kit.Kit) error {
+	return kit.Render(ForgotPasswordIndex(ForgotPasswordFormValues{}))
+}
+
+func HandlePasswordForgotCreate(kit *kit.Kit) error {
+	var values ForgotPasswordFormValues
+	errors, ok := v.Request(kit.Request, &values, forgotPasswordSchema)
+	if !ok {
+		return kit.Render(ForgotPasswordForm(values, errors))
+	}
+
+	// Never tell the client whether an account exists for the given email.
+	var user User
+	err := db.Get().Find(&user, "email = ?", values.Email).Error
+	if err != nil {
+		return err
+	}
+	if user.ID > 0 {
+		token, err := createUserToken(user.ID, TokenPurposePasswordReset, passwordResetExpiry())
+		if err != nil {
+			return err
+		}
+		event.Emit(PasswordResetEvent, UserWithResetToken{
+			User:  user,
+			Token: token,
+		})
+	}
+
+	return kit.Render(ForgotPasswordSent(values.Email))
+}
+
+func HandlePasswordResetIndex(kit *kit.Kit) error {
+	token := kit.Request.URL.Query().Get("token")
+	if _, err := findUserToken(db.Get(), TokenPurposePasswordReset, token); err != nil {
+		if errors.Is(err, errInvalidToken) {
+			return kit.Render(EmailVerificationError("Password reset link is invalid or has expired"))
+		}
+		return err
+	}
+	return kit.Render(ResetPasswordIndex(ResetPasswordFormValues{Token: token}))
+}
+
+func HandlePasswordResetCreate(kit *kit.Kit) error {
+	var values ResetPasswordFormValues
+	errs, ok := v.Request(kit.Request, &values, resetPasswordSchema)
+	if !ok {
+		return kit.Render(ResetPasswordForm(values, errs))
+	}
+	breached, err := passwordBreached(values.Password)
+	if err != nil {
+		return err
+	}
+	if breached {
+		errs.Add("password", breachedPasswordMessage)
+		return kit.Render(ResetPasswordForm(values, errs))
+	}
+
+	hash, err := hashPassword(values.Password)
+	if err != nil {
+		return err
+	}
+	var userID uint
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, TokenPurposePasswordReset, values.Token)
+		if err != nil {
+			return err
+		}
+		userID = userToken.UserID
+		err = tx.Model(&User{}).
+			Where("id = ?", userToken.UserID).
+			Update("password_hash", hash).Error
+		if err != nil {
+			return err
+		}
+		return deleteUserSessions(tx, userToken.UserID)
+	})
+	if errors.Is(err, errInvalidToken) {
+		errs.Add("token", "password reset link is invalid or has expired")
+		return kit.Render(ResetPasswordForm(values, errs))
+	}
+	if err != nil {
+		return err
+	}
+	recordActivity(kit, userID, ActivityPasswordReset, "")
+
+	if err := kit.FlashSuccess("Your password has been reset. You can log in with your new password."); err != nil {
+		return err
+	}
+	return kit.Redirect(http.StatusSeeOther, "/login")
+}
+
+func passwordResetExpiry() time.Duration {
+	return time.Hour * time.Duration(config.Auth.PasswordResetExpiryInHours)
+}
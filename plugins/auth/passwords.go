@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"gothstack/app/db"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const breachedPasswordMessage = "this password appeared in a data breach, please choose another one"
+
+// passwordCost returns the bcrypt cost for new password and PIN hashes
+// from SUPERKIT_AUTH_BCRYPT_COST.
+func passwordCost() int {
+	return min(max(config.Auth.BcryptCost, bcrypt.MinCost), bcrypt.MaxCost)
+}
+
+// dummyPasswordHash is compared against when no user matches the login,
+// so the request takes as long as for a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), passwordCost())
+	return hash
+})
+
+// rehashIfWeak replaces the hash stored in the given column of the user
+// when it was created with a lower cost than the current policy. It must
+// only be called after the secret was verified against the hash.
+func rehashIfWeak(userID uint, column, hash, secret string) error {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil || cost >= passwordCost() {
+		return err
+	}
+	newHash, err := hashPassword(secret)
+	if err != nil {
+		return err
+	}
+	return db.Get().Model(&User{}).Where("id = ?", userID).Update(column, newHash).Error
+}
+
+// passwordBreached returns true if the password is in the offline breached
+// password list configured with SUPERKIT_AUTH_BREACHED_PASSWORDS_FILE. The
+// list uses the SHA-1 format of Have I Been Pwned, either as a directory of
+// range files named after the first 5 characters of the hash that hold
+// "SUFFIX:COUNT" lines, or as a single file of "HASH:COUNT" lines sorted by
+// hash. Without a configured list no password is considered breached.
+func passwordBreached(password string) (bool, error) {
+	path := config.Auth.BreachedPasswordsFile
+	if len(path) == 0 {
+		return false, nil
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return breachedInRangeFile(path, hash)
+	}
+	return breachedInSortedFile(path, info.Size(), hash)
+}
+
+func breachedInRangeFile(dir, hash string) (bool, error) {
+	prefix, suffix := hash[:5], hash[5:]
+	f, err := os.Open(filepath.Join(dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = os.Open(filepath.Join(dir, prefix+".txt"))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if breachedLineHash(scanner.Text()) == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// breachedInSortedFile binary searches the file for the hash, so even the
+// full list of several gigabytes only takes a few dozen reads.
+func breachedInSortedFile(path string, size int64, hash string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// Find the first line at or after the offset whose hash is not less
+	// than the searched hash.
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, ok, err := lineAtOrAfter(f, size, mid)
+		if err != nil {
+			return false, err
+		}
+		if !ok || breachedLineHash(line) >= hash {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	line, ok, err := lineAtOrAfter(f, size, lo)
+	if err != nil {
+		return false, err
+	}
+	return ok && breachedLineHash(line) == hash, nil
+}
+
+// lineAtOrAfter returns the first line that starts at or after the offset.
+func lineAtOrAfter(f *os.File, size, offset int64) (string, bool, error) {
+	start := max(offset-1, 0)
+	r := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	if offset > 0 {
+		if _, err := r.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return "", false, nil
+			}
+			return "", false, err
+		}
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	if len(line) == 0 {
+		return "", false, nil
+	}
+	return line, true, nil
+}
+
+func breachedLineHash(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
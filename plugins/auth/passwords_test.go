@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"gothstack/app/conf"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// breachedPasswords returns the passwords in the test lists, with enough
+// of them that the binary search takes several steps.
+func breachedPasswords() []string {
+	passwords := []string{"password", "123456", "qwerty"}
+	for i := range 500 {
+		passwords = append(passwords, fmt.Sprintf("breached-%d", i))
+	}
+	return passwords
+}
+
+// writeSortedList writes the passwords as "HASH:COUNT" lines sorted by
+// hash, with the given line ending.
+func writeSortedList(t *testing.T, passwords []string, lower bool, newline string, trailing bool) string {
+	t.Helper()
+	var lines []string
+	for i, password := range passwords {
+		hash := sha1Hex(password)
+		if lower {
+			hash = strings.ToLower(hash)
+		}
+		lines = append(lines, fmt.Sprintf("%s:%d", hash, i+1))
+	}
+	slices.SortFunc(lines, func(a, b string) int {
+		return strings.Compare(strings.ToUpper(a), strings.ToUpper(b))
+	})
+	data := strings.Join(lines, newline)
+	if trailing {
+		data += newline
+	}
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPasswordBreachedSortedFile(t *testing.T) {
+	passwords := breachedPasswords()
+	// The first and last hashes are the edges of the search.
+	byHash := slices.Clone(passwords)
+	slices.SortFunc(byHash, func(a, b string) int {
+		return strings.Compare(sha1Hex(a), sha1Hex(b))
+	})
+	first, last := byHash[0], byHash[len(byHash)-1]
+
+	lists := []struct {
+		name     string
+		lower    bool
+		newline  string
+		trailing bool
+	}{
+		{"LF", false, "\n", true},
+		{"CRLF", false, "\r\n", true},
+		{"no trailing newline", false, "\n", false},
+		{"lowercase hashes", true, "\n", true},
+	}
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"breached-250", true},
+		{first, true},
+		{last, true},
+		{"not breached", false},
+		{"breached-500", false},
+		{"", false},
+	}
+	for _, list := range lists {
+		t.Run(list.name, func(t *testing.T) {
+			path := writeSortedList(t, passwords, list.lower, list.newline, list.trailing)
+			config = &conf.Config{Auth: conf.Auth{BreachedPasswordsFile: path}}
+			for _, tt := range tests {
+				got, err := passwordBreached(tt.password)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("passwordBreached(%q) = %v, want %v", tt.password, got, tt.want)
+				}
+			}
+			// Every password in the list is found wherever it sits.
+			for _, password := range passwords {
+				if ok, err := passwordBreached(password); err != nil || !ok {
+					t.Fatalf("passwordBreached(%q) = %v, %v, want true", password, ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordBreachedSmallFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		passwords []string
+		password  string
+		want      bool
+	}{
+		{"empty file", nil, "password", false},
+		{"single line", []string{"password"}, "password", true},
+		{"single other line", []string{"password"}, "123456", false},
+		{"two lines, first", []string{"password", "123456"}, "password", true},
+		{"two lines, second", []string{"password", "123456"}, "123456", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeSortedList(t, tt.passwords, false, "\n", true)
+			config = &conf.Config{Auth: conf.Auth{BreachedPasswordsFile: path}}
+			got, err := passwordBreached(tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("passwordBreached(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordBreachedRangeFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Range files may be named with or without the .txt extension.
+	write := func(password, name string) {
+		hash := sha1Hex(password)
+		data := "0000000000000000000000000000000000A:1\r\n" + hash[5:] + ":42\r\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("password", sha1Hex("password")[:5])
+	write("123456", sha1Hex("123456")[:5]+".txt")
+	config = &conf.Config{Auth: conf.Auth{BreachedPasswordsFile: dir}}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"123456", true},
+		{"qwerty", false},
+	}
+	for _, tt := range tests {
+		got, err := passwordBreached(tt.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("passwordBreached(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordBreachedWithoutList(t *testing.T) {
+	config = &conf.Config{}
+	if ok, err := passwordBreached("password"); err != nil || ok {
+		t.Errorf("passwordBreached without a list = %v, %v, want false", ok, err)
+	}
+
+	config = &conf.Config{Auth: conf.Auth{BreachedPasswordsFile: filepath.Join(t.TempDir(), "missing")}}
+	if _, err := passwordBreached("password"); err == nil {
+		t.Error("passwordBreached with a missing list returned no error")
+	}
+}
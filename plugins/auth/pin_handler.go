@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	phoneRegex = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+	pinRegex   = regexp.MustCompile(`^[0-9]{4,6}$`)
+)
+
+// phoneRule accepts phone numbers after normalizePhone removed formatting.
+var phoneRule = v.RuleSet{
+	Name: "phone",
+	ValidateFunc: func(rule v.RuleSet) bool {
+		str, ok := rule.FieldValue.(string)
+		return ok && phoneRegex.MatchString(normalizePhone(str))
+	},
+	MessageFunc: func(rule v.RuleSet) string {
+		return "invalid phone number"
+	},
+}
+
+// pinRule accepts PINs of 4 to 6 digits.
+var pinRule = v.RuleSet{
+	Name: "pin",
+	ValidateFunc: func(rule v.RuleSet) bool {
+		str, ok := rule.FieldValue.(string)
+		return ok && pinRegex.MatchString(str)
+	},
+	MessageFunc: func(rule v.RuleSet) string {
+		return "should be 4 to 6 digits"
+	},
+}
+
+var pinLoginSchema = v.Schema{
+	"phone": v.Rules(phoneRule),
+	"pin":   v.Rules(v.Required),
+}
+
+var removePINSchema = v.Schema{
+	"email": v.Rules(v.Email),
+}
+
+var setPINSchema = v.Schema{
+	"email": v.Rules(v.Email),
+	"phone": v.Rules(phoneRule),
+	"pin":   v.Rules(pinRule),
+}
+
+func HandlePINLoginIndex(kit *kit.Kit) error {
+	return kit.Render(PINLoginIndex(PINLoginFormValues{}))
+}
+
+// HandlePINLoginCreate logs the user in with their phone number and PIN.
+// PINs are short, so every failure counts towards the login throttle.
+func HandlePINLoginCreate(kit *kit.Kit) error {
+	var values PINLoginFormValues
+	errors, ok := v.Request(kit.Request, &values, pinLoginSchema)
+	if !ok {
+		return kit.Render(PINLoginForm(values, errors))
+	}
+	phone := normalizePhone(values.Phone)
+
+	lockedUntil, locked, err := loginLockedUntil(accountThrottleKey(phone), ipThrottleKey(clientIP(kit.Request)))
+	if err != nil {
+		return err
+	}
+	if locked {
+		errors.Add("credentials", lockoutMessage(lockedUntil))
+		return kit.Render(PINLoginForm(values, errors))
+	}
+
+	var user User
+	err = db.Get().Find(&user, "phone = ? AND pin_hash <> ''", phone).Error
+	if err != nil {
+		return err
+	}
+	pinHash := []byte(user.PINHash)
+	if user.ID == 0 {
+		pinHash = dummyPasswordHash()
+	}
+	if bcrypt.CompareHashAndPassword(pinHash, []byte(values.PIN)) != nil || user.ID == 0 {
+		if err := recordLoginFailure(kit, phone, user); err != nil {
+			return err
+		}
+		errors.Add("credentials", "invalid phone number or PIN")
+		return kit.Render(PINLoginForm(values, errors))
+	}
+
+	if err := rehashIfWeak(user.ID, "pin_hash", user.PINHash, values.PIN); err != nil {
+		return err
+	}
+	if err := clearLoginFailures(accountThrottleKey(phone)); err != nil {
+		return err
+	}
+	return completeLogin(kit, user)
+}
+
+func HandleStaffPINIndex(kit *kit.Kit) error {
+	return kit.Render(StaffPINIndex(SetPINFormValues{}))
+}
+
+// HandleStaffPINCreate lets staff set up phone and PIN login for a
+// customer who has trouble using passwords.
+func HandleStaffPINCreate(kit *kit.Kit) error {
+	var values SetPINFormValues
+	errors, ok := v.Request(kit.Request, &values, setPINSchema)
+	if !ok {
+		return kit.Render(SetPINForm(values, errors))
+	}
+	phone := normalizePhone(values.Phone)
+
+	var user User
+	if err := db.Get().Find(&user, "email = ?", values.Email).Error; err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		errors.Add("email", "no account with this email")
+		return kit.Render(SetPINForm(values, errors))
+	}
+	var count int64
+	err := db.Get().Model(&User{}).Where("phone = ? AND id <> ?", phone, user.ID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		errors.Add("phone", "phone number is already used by another account")
+		return kit.Render(SetPINForm(values, errors))
+	}
+
+	hash, err := hashPassword(values.PIN)
+	if err != nil {
+		return err
+	}
+	err = db.Get().Model(&user).Updates(map[string]any{
+		"phone":    phone,
+		"pin_hash": hash,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	values = SetPINFormValues{Success: "PIN login set up for " + user.Email}
+	return kit.Render(SetPINForm(values, errors))
+}
+
+// HandleStaffPINDelete turns off PIN login for the given account.
+func HandleStaffPINDelete(kit *kit.Kit) error {
+	var values RemovePINFormValues
+	errors, ok := v.Request(kit.Request, &values, removePINSchema)
+	if !ok {
+		return kit.Render(RemovePINForm(values, errors))
+	}
+	result := db.Get().Model(&User{}).
+		Where("email = ? AND pin_hash <> ''", values.Email).
+		Update("pin_hash", "")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		errors.Add("email", "no account with PIN login for this email")
+		return kit.Render(RemovePINForm(values, errors))
+	}
+
+	values = RemovePINFormValues{Success: "PIN login turned off for " + values.Email}
+	return kit.Render(RemovePINForm(values, errors))
+}
+
+// normalizePhone removes spaces and common separators from a phone number.
+func normalizePhone(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(strings.TrimSpace(phone))
+}
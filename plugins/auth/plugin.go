@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"embed"
+	"gothstack/app/plugin"
+	"gothstack/app/views/emails"
+	"io/fs"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Plugin is the authentication plugin: accounts, sessions, login methods
+// and the user administration.
+type Plugin struct {
+	plugin.Base
+}
+
+func (Plugin) Name() string { return "auth" }
+
+func (Plugin) Init(app plugin.App) error {
+	config = app.Config
+	mailer = emails.NewMailer(app.Config, mailFS, "mail/*.txt")
+	return nil
+}
+
+func (Plugin) Routes(router chi.Router, app plugin.App) {
+	InitRoutes(router, app.Auth)
+}
+
+// Events sends the emails of the plugin.
+func (Plugin) Events() []plugin.Subscription {
+	return []plugin.Subscription{
+		{Topic: UserSignupEvent, Handler: onUserSignup},
+		{Topic: ResendVerificationEvent, Handler: onResendVerificationToken},
+		{Topic: PasswordResetEvent, Handler: onPasswordReset},
+		{Topic: AccountLockedEvent, Handler: onAccountLocked},
+		{Topic: MagicLinkEvent, Handler: onMagicLink},
+		{Topic: EmailChangeEvent, Handler: onEmailChange},
+		{Topic: EmailChangeNoticeEvent, Handler: onEmailChangeNotice},
+		{Topic: AccountDeletedEvent, Handler: onAccountDeleted},
+		{Topic: InviteCreatedEvent, Handler: onInviteCreated},
+	}
+}
+
+func (Plugin) Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
+
+func (Plugin) Nav() []plugin.NavItem {
+	return []plugin.NavItem{
+		{Label: "Login", URL: "/login", Section: plugin.NavPublic},
+		{Label: "Sign Up", URL: "/signup", Section: plugin.NavPublic},
+		{Label: "PIN login", URL: "/staff/pins", Section: plugin.NavAdmin, Permission: PermissionManageLoginPINs},
+		{Label: "Invites", URL: "/staff/invites", Section: plugin.NavAdmin, Permission: PermissionManageInvites},
+		{Label: "Users", URL: "/admin/users", Section: plugin.NavAdmin, Permission: PermissionManageUsers},
+		{Label: "Lockouts", URL: "/admin/lockouts", Section: plugin.NavAdmin, Permission: PermissionManageUsers},
+	}
+}
+
+func (Plugin) Jobs() []plugin.Job {
+	return []plugin.Job{
+		{
+			Name:     "purge-sessions",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				return purgeExpiredSessions()
+			},
+		},
+	}
+}
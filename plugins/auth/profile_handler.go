@@ -1,11 +1,11 @@
 package auth
 
 import (
-	"gothstack/app/db"
 	"fmt"
-
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
+	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"net/http"
 )
 
 var profileSchema = v.Schema{
@@ -29,14 +29,38 @@ func HandleProfileShow(kit *kit.Kit) error {
 		return err
 	}
 
-	formValues := ProfileFormValues{
-		ID:        user.ID,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
+	sessions, err := activeSessions(user.ID)
+	if err != nil {
+		return err
+	}
+	accounts, err := connectedAccounts(user.ID)
+	if err != nil {
+		return err
+	}
+	tokens, err := apiTokenSectionData(auth)
+	if err != nil {
+		return err
+	}
+
+	data := ProfilePageData{
+		FormValues: ProfileFormValues{
+			ID:        user.ID,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Email:     user.Email,
+		},
+		Sessions:            sessions,
+		CurrentSessionToken: currentSessionToken(kit),
+		TwoFactor: TwoFactorSectionData{
+			Enabled:  user.TwoFactorEnabled(),
+			Required: twoFactorRequired(user.Role),
+		},
+		ConnectedAccounts: accounts,
+		APITokens:         tokens,
+		HasPassword:       len(user.PasswordHash) > 0,
 	}
 
-	return kit.Render(ProfileShow(formValues))
+	return kit.Render(ProfileShow(data))
 }
 
 func HandleProfileUpdate(kit *kit.Kit) error {
@@ -48,7 +72,7 @@ func HandleProfileUpdate(kit *kit.Kit) error {
 
 	auth := kit.Auth().(Auth)
 	if auth.UserID != values.ID {
-		return fmt.Errorf("unauthorized request for profile %d", values.ID)
+		return kit.Error(http.StatusForbidden, "", fmt.Errorf("unauthorized request for profile %d", values.ID))
 	}
 	err := db.Get().Model(&User{}).
 		Where("id = ?", auth.UserID).
@@ -0,0 +1,57 @@
+package auth
+
+import "slices"
+
+// Roles that can be assigned to User.Role.
+const (
+	RoleUser   = "user"
+	RoleStaff  = "staff"
+	RoleDriver = "driver"
+	RoleAdmin  = "admin"
+)
+
+// Named permissions granted by roles. Handlers and views should prefer
+// checking a permission with Auth.Can over checking a specific role.
+const (
+	PermissionManageMeals     = "meals.manage"
+	PermissionViewOrders      = "orders.view"
+	PermissionViewDeliveries  = "deliveries.view"
+	PermissionManageTimeSlots = "timeslots.manage"
+	PermissionManageUsers     = "users.manage"
+	PermissionManageLoginPINs = "users.pins"
+	PermissionManageInvites   = "users.invites"
+)
+
+var rolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleDriver: {
+		PermissionViewDeliveries,
+	},
+	RoleStaff: {
+		PermissionManageMeals,
+		PermissionViewOrders,
+		PermissionViewDeliveries,
+		PermissionManageLoginPINs,
+		PermissionManageInvites,
+	},
+	RoleAdmin: {
+		PermissionManageMeals,
+		PermissionViewOrders,
+		PermissionViewDeliveries,
+		PermissionManageTimeSlots,
+		PermissionManageUsers,
+		PermissionManageLoginPINs,
+		PermissionManageInvites,
+	},
+}
+
+// Roles returns every role that can be assigned to a user.
+func Roles() []string {
+	return []string{RoleUser, RoleDriver, RoleStaff, RoleAdmin}
+}
+
+// PermissionsForRole returns the permissions granted to the given role.
+// Unknown roles are granted no permissions.
+func PermissionsForRole(role string) []string {
+	return slices.Clone(rolePermissions[role])
+}
@@ -1,19 +1,27 @@
 package auth
 
 import (
-	"github.com/anthdm/superkit/kit"
+	"gothstack/app/conf"
+	"gothstack/kit"
+
 	"github.com/go-chi/chi/v5"
 )
 
-func InitializeRoutes(router chi.Router, authConfig kit.AuthenticationConfig) {
+// config is the application configuration, set by Plugin.Init.
+var config *conf.Config
+
+func InitRoutes(router chi.Router, authConfig kit.AuthenticationConfig) {
 	/* 	authConfig := kit.AuthenticationConfig{
-		AuthFunc:    AuthenticateUser,
+		AuthFunc:    Authenticate,
 		RedirectURL: "/login",
 	} */
 	// Routes that don't require any authentication
 	// These endpoints are publicly accessible
 	router.Get("/email/verify", kit.Handler(HandleEmailVerify))
 	router.Post("/resend-email-verification", kit.Handler(HandleResendVerificationCode))
+	router.Get("/account/unlock", kit.Handler(HandleAccountUnlock))
+	router.Get("/account/disabled", kit.Handler(HandleAccountDisabled))
+	router.Get("/email/change/confirm", kit.Handler(HandleEmailChangeConfirm))
 
 	// First router group: Authentication-related routes (login/signup flows)
 	// The false parameter in WithAuthentication means authentication is NOT required
@@ -25,6 +33,25 @@ func InitializeRoutes(router chi.Router, authConfig kit.AuthenticationConfig) {
 		auth.Delete("/logout", kit.Handler(HandleLoginDelete)) // Log user out
 		auth.Get("/signup", kit.Handler(HandleSignupIndex))    // Show signup page
 		auth.Post("/signup", kit.Handler(HandleSignupCreate))  // Process signup form
+
+		auth.Get("/password/forgot", kit.Handler(HandlePasswordForgotIndex))   // Show forgot password page
+		auth.Post("/password/forgot", kit.Handler(HandlePasswordForgotCreate)) // Send password reset link
+		auth.Get("/password/reset", kit.Handler(HandlePasswordResetIndex))     // Show reset password form
+		auth.Post("/password/reset", kit.Handler(HandlePasswordResetCreate))   // Process reset password form
+
+		auth.Get("/login/2fa", kit.Handler(HandleTwoFactorIndex))             // Show second login step
+		auth.Post("/login/2fa", kit.Handler(HandleTwoFactorCreate))           // Verify authentication code
+		auth.Post("/login/2fa/setup", kit.Handler(HandleTwoFactorLoginSetup)) // Confirm required enrollment
+
+		auth.Get("/login/oidc/{provider}", kit.Handler(HandleOIDCLogin))             // Redirect to identity provider
+		auth.Get("/login/oidc/{provider}/callback", kit.Handler(HandleOIDCCallback)) // Complete identity provider login
+
+		auth.Get("/login/magic", kit.Handler(HandleMagicLinkIndex))                // Show magic link form
+		auth.Post("/login/magic", kit.Handler(HandleMagicLinkCreate))              // Send magic link
+		auth.Get("/login/magic/verify", kit.Handler(HandleMagicLinkVerifyIndex))   // Confirm magic link login
+		auth.Post("/login/magic/verify", kit.Handler(HandleMagicLinkVerifyCreate)) // Log in with magic link
+		auth.Get("/login/pin", kit.Handler(HandlePINLoginIndex))                   // Show phone and PIN login
+		auth.Post("/login/pin", kit.Handler(HandlePINLoginCreate))                 // Log in with phone and PIN
 	})
 
 	// Second router group: Protected routes (require authentication)
@@ -32,7 +59,54 @@ func InitializeRoutes(router chi.Router, authConfig kit.AuthenticationConfig) {
 	// These routes are for already authenticated users
 	router.Group(func(auth chi.Router) {
 		auth.Use(kit.WithAuthentication(authConfig, true))
-		auth.Get("/profile", kit.Handler(HandleProfileShow))   // View user profile
-		auth.Put("/profile", kit.Handler(HandleProfileUpdate)) // Update user profile
+		auth.Use(withSessionAuth)
+		auth.Get("/profile", kit.Handler(HandleProfileShow))              // View user profile
+		auth.Put("/profile", kit.Handler(HandleProfileUpdate))            // Update user profile
+		auth.Post("/profile/email", kit.Handler(HandleEmailChangeCreate)) // Request email change
+		auth.Get("/profile/export", kit.Handler(HandleUserDataExport))    // Download personal data
+		auth.Post("/profile/delete", kit.Handler(HandleAccountDelete))    // Delete account
+
+		auth.Delete("/profile/sessions", kit.Handler(HandleSessionDeleteAll))   // Log out everywhere
+		auth.Delete("/profile/sessions/{id}", kit.Handler(HandleSessionDelete)) // Revoke a single session
+
+		auth.Get("/profile/2fa/setup", kit.Handler(HandleTwoFactorSetupIndex))           // Start two-factor enrollment
+		auth.Post("/profile/2fa/setup", kit.Handler(HandleTwoFactorSetupCreate))         // Confirm two-factor enrollment
+		auth.Post("/profile/2fa/recovery-codes", kit.Handler(HandleRecoveryCodesCreate)) // Regenerate recovery codes
+		auth.Post("/profile/2fa/disable", kit.Handler(HandleTwoFactorDisable))           // Disable two-factor authentication
+		auth.Delete("/profile/identities/{id}", kit.Handler(HandleIdentityDelete))       // Disconnect identity provider
+		auth.Post("/profile/tokens", kit.Handler(HandleAPITokenCreate))                  // Create API token
+		auth.Delete("/profile/tokens/{id}", kit.Handler(HandleAPITokenDelete))           // Revoke API token
+
+		// Staff routes for setting up PIN login
+		auth.Group(func(staff chi.Router) {
+			staff.Use(kit.WithPermission(PermissionManageLoginPINs))
+			staff.Get("/staff/pins", kit.Handler(HandleStaffPINIndex))          // Show PIN setup
+			staff.Post("/staff/pins", kit.Handler(HandleStaffPINCreate))        // Set phone and PIN
+			staff.Post("/staff/pins/remove", kit.Handler(HandleStaffPINDelete)) // Turn off PIN login
+		})
+
+		// Staff routes for inviting customers when signup is invite only
+		auth.Group(func(staff chi.Router) {
+			staff.Use(kit.WithPermission(PermissionManageInvites))
+			staff.Get("/staff/invites", kit.Handler(HandleInviteIndex))          // List invites
+			staff.Post("/staff/invites", kit.Handler(HandleInviteCreate))        // Create invite
+			staff.Delete("/staff/invites/{id}", kit.Handler(HandleInviteDelete)) // Revoke invite
+		})
+
+		// Admin routes for managing accounts
+		auth.Group(func(admin chi.Router) {
+			admin.Use(kit.WithPermission(PermissionManageUsers))
+			admin.Get("/admin/users", kit.Handler(HandleUserAdminIndex))                              // List and search users
+			admin.Get("/admin/users/{id}", kit.Handler(HandleUserAdminShow))                          // Show user details
+			admin.Post("/admin/users/{id}/role", kit.Handler(HandleUserAdminRoleUpdate))              // Change role
+			admin.Post("/admin/users/{id}/verify", kit.Handler(HandleUserAdminVerify))                // Mark email verified
+			admin.Post("/admin/users/{id}/disable", kit.Handler(HandleUserAdminDisable))              // Disable account
+			admin.Post("/admin/users/{id}/enable", kit.Handler(HandleUserAdminEnable))                // Enable account
+			admin.Post("/admin/users/{id}/password-reset", kit.Handler(HandleUserAdminPasswordReset)) // Send password reset link
+			admin.Delete("/admin/users/{id}/sessions", kit.Handler(HandleUserAdminSessionsDelete))    // Log user out everywhere
+
+			admin.Get("/admin/lockouts", kit.Handler(HandleLockoutIndex))          // List login lockouts
+			admin.Delete("/admin/lockouts/{id}", kit.Handler(HandleLockoutDelete)) // Lift a lockout
+		})
 	})
 }
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"gothstack/kit"
+	"net/http"
+)
+
+// HandleSessionDelete revokes one of the authenticated user's sessions.
+func HandleSessionDelete(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	id, err := kit.URLParamID("id")
+	if err != nil {
+		return err
+	}
+	err = db.Get().Unscoped().
+		Where("id = ? AND user_id = ?", id, auth.UserID).
+		Delete(&Session{}).Error
+	if err != nil {
+		return err
+	}
+
+	sessions, err := activeSessions(auth.UserID)
+	if err != nil {
+		return err
+	}
+	return kit.Render(SessionList(sessions, currentSessionToken(kit)))
+}
+
+// HandleSessionDeleteAll logs the authenticated user out everywhere,
+// including the current device.
+func HandleSessionDeleteAll(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	err := db.Get().Unscoped().
+		Where("user_id = ?", auth.UserID).
+		Delete(&Session{}).Error
+	if err != nil {
+		return err
+	}
+
+	sess := kit.GetSession(userSessionName)
+	sess.Values = map[any]any{}
+	sess.Save(kit.Request, kit.Response)
+
+	return kit.Redirect(http.StatusSeeOther, "/login")
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"gothstack/kit"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// createSession creates a new session for the given user, remembering the
+// device it was created from, and stores its token in the cookie session.
+func createSession(kit *kit.Kit, user User) error {
+	session := Session{
+		UserID:    user.ID,
+		Token:     uuid.New().String(),
+		IPAddress: clientIP(kit.Request),
+		UserAgent: kit.Request.UserAgent(),
+		ExpiresAt: time.Now().Add(time.Hour * time.Duration(config.Auth.SessionExpiryInHours)),
+	}
+	if err := db.Get().Create(&session).Error; err != nil {
+		return err
+	}
+	recordActivity(kit, user.ID, ActivityLogin, session.UserAgent)
+
+	sess := kit.GetSession(userSessionName)
+	sess.Values["sessionToken"] = session.Token
+	return sess.Save(kit.Request, kit.Response)
+}
+
+// purgeExpiredSessions permanently removes expired and logged out sessions.
+// Every login creates a row, so the plugin runs it as a job to keep the
+// sessions table from growing without limit.
+func purgeExpiredSessions() error {
+	return db.Get().Unscoped().
+		Where("expires_at <= ? OR deleted_at IS NOT NULL", time.Now()).
+		Delete(&Session{}).Error
+}
+
+// activeSessions returns the unexpired sessions of the given user,
+// newest first.
+func activeSessions(userID uint) ([]Session, error) {
+	var sessions []Session
+	err := db.Get().
+		Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("created_at desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// currentSessionToken returns the session token of the current request.
+func currentSessionToken(kit *kit.Kit) string {
+	sess := kit.GetSession(userSessionName)
+	token, _ := sess.Values["sessionToken"].(string)
+	return token
+}
+
+// clientIP returns the address of the client, read from the headers of
+// the trusted proxies set with HTTP_TRUSTED_PROXIES.
+func clientIP(r *http.Request) string {
+	return kit.ClientIP(r)
+}
@@ -1,46 +1,92 @@
 package auth
 
 import (
-	"gothstack/app/db"
 	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	v "gothstack/kit/validate"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/anthdm/superkit/event"
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// passwordRules are the rules every new password has to satisfy.
+var passwordRules = v.Rules(
+	v.ContainsSpecial,
+	v.ContainsUpper,
+	v.Min(7),
+	v.Max(50),
+)
+
 var signupSchema = v.Schema{
-	"email": v.Rules(v.Email),
-	"password": v.Rules(
-		v.ContainsSpecial,
-		v.ContainsUpper,
-		v.Min(7),
-		v.Max(50),
-	),
-	"firstName": v.Rules(v.Min(2), v.Max(50)),
-	"lastName":  v.Rules(v.Min(2), v.Max(50)),
+	"email":           v.Rules(v.Email),
+	"password":        passwordRules,
+	"firstName":       v.Rules(v.Min(2), v.Max(50)),
+	"lastName":        v.Rules(v.Min(2), v.Max(50)),
+	"passwordConfirm": v.Rules(v.EqualTo("password").Message("passwords do not match")),
 }
 
 func HandleSignupIndex(kit *kit.Kit) error {
-	return kit.Render(SignupIndex(SignupIndexPageData{}))
+	values := SignupFormValues{InviteOnly: inviteOnly()}
+	code := kit.Request.URL.Query().Get("invite")
+	if values.InviteOnly && len(code) > 0 {
+		invite, err := findInvite(db.Get(), code)
+		if err == errInvalidInvite {
+			return kit.Render(EmailVerificationError("Invite link is invalid, already used or has expired"))
+		}
+		if err != nil {
+			return err
+		}
+		values.Invite = code
+		values.Email = invite.Email
+		values.FirstName = invite.FirstName
+		values.LastName = invite.LastName
+	}
+	return kit.Render(SignupIndex(SignupIndexPageData{FormValues: values}))
 }
 
 func HandleSignupCreate(kit *kit.Kit) error {
 	var values SignupFormValues
 	errors, ok := v.Request(kit.Request, &values, signupSchema)
+	values.InviteOnly = inviteOnly()
 	if !ok {
 		return kit.Render(SignupForm(values, errors))
 	}
-	if values.Password != values.PasswordConfirm {
-		errors.Add("passwordConfirm", "passwords do not match")
+	breached, err := passwordBreached(values.Password)
+	if err != nil {
+		return err
+	}
+	if breached {
+		errors.Add("password", breachedPasswordMessage)
+		return kit.Render(SignupForm(values, errors))
+	}
+
+	var invite *Invite
+	if values.InviteOnly {
+		found, err := findInvite(db.Get(), values.Invite)
+		if err == errInvalidInvite {
+			errors.Add("invite", "invite code is invalid, already used or has expired")
+			return kit.Render(SignupForm(values, errors))
+		}
+		if err != nil {
+			return err
+		}
+		if len(found.Email) > 0 && !strings.EqualFold(found.Email, values.Email) {
+			errors.Add("email", "this invite is for a different email")
+			return kit.Render(SignupForm(values, errors))
+		}
+		invite = &found
+	}
+
+	user, err := createUserFromFormValues(values, invite)
+	if err == errInvalidInvite {
+		errors.Add("invite", "invite code is invalid, already used or has expired")
 		return kit.Render(SignupForm(values, errors))
 	}
-	user, err := createUserFromFormValues(values)
 	if err != nil {
 		return err
 	}
@@ -56,8 +102,7 @@ func HandleSignupCreate(kit *kit.Kit) error {
 }
 
 func HandleResendVerificationCode(kit *kit.Kit) error {
-	idstr := kit.FormValue("userID")
-	id, err := strconv.Atoi(idstr)
+	id, err := kit.FormID("userID")
 	if err != nil {
 		return err
 	}
@@ -86,19 +131,47 @@ func HandleResendVerificationCode(kit *kit.Kit) error {
 	return kit.Text(http.StatusOK, msg)
 }
 
+// emailVerifyTokenAudience separates the signup verification tokens from
+// the other signed user tokens.
+const emailVerifyTokenAudience = "email-verify"
+
 func createVerificationToken(userID uint) (string, error) {
-	expiryStr := kit.Getenv("SUPERKIT_AUTH_EMAIL_VERIFICATION_EXPIRY_IN_HOURS", "1")
-	expiry, err := strconv.Atoi(expiryStr)
-	if err != nil {
-		expiry = 1
-	}
+	return createSignedUserToken(userID, emailVerifyTokenAudience, time.Hour*time.Duration(config.Auth.EmailVerificationExpiryInHours))
+}
 
+// createSignedUserToken creates a JWT for the given user that is signed with
+// SUPERKIT_SECRET. The audience separates tokens with different purposes.
+func createSignedUserToken(userID uint, audience string, expiry time.Duration) (string, error) {
 	claims := jwt.RegisteredClaims{
 		Subject:   fmt.Sprint(userID),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expiry))),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
+	}
+	if len(audience) > 0 {
+		claims.Audience = jwt.ClaimStrings{audience}
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	return token.SignedString([]byte(os.Getenv("SUPERKIT_SECRET")))
+	return token.SignedString([]byte(config.Secret))
+}
+
+// parseSignedUserToken validates a token created by createSignedUserToken
+// for the given audience and returns the ID of its user.
+func parseSignedUserToken(tokenStr, audience string) (uint, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenStr, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
+			return []byte(config.Secret), nil
+		}, jwt.WithLeeway(5*time.Second), jwt.WithAudience(audience), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, err
+	}
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid {
+		return 0, errInvalidToken
+	}
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, errInvalidToken
+	}
+	return uint(userID), nil
 }
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttempt counts the failed logins of a single throttle key. There is
+// one key per account and one per client IP address.
+type LoginAttempt struct {
+	gorm.Model
+
+	Key         string `gorm:"column:throttle_key"`
+	Failures    int
+	LockedUntil sql.NullTime
+}
+
+// AccountLockout records that a throttle key got locked so admins can see
+// which accounts and addresses are under attack.
+type AccountLockout struct {
+	gorm.Model
+
+	Key         string `gorm:"column:throttle_key"`
+	UserID      *uint
+	IPAddress   string
+	Failures    int
+	LockedUntil time.Time
+	UnlockedAt  sql.NullTime
+	User        *User
+}
+
+// Active returns true if the lockout has not expired or been lifted.
+func (lockout AccountLockout) Active() bool {
+	return !lockout.UnlockedAt.Valid && lockout.LockedUntil.After(time.Now())
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginLockedUntil returns the latest time until which any of the given
+// keys is locked.
+func loginLockedUntil(keys ...string) (time.Time, bool, error) {
+	var attempts []LoginAttempt
+	err := db.Get().
+		Where("throttle_key IN ? AND locked_until > ?", keys, time.Now()).
+		Find(&attempts).Error
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var until time.Time
+	for _, attempt := range attempts {
+		if attempt.LockedUntil.Time.After(until) {
+			until = attempt.LockedUntil.Time
+		}
+	}
+	return until, len(attempts) > 0, nil
+}
+
+// recordLoginFailure counts a failed login against the account and the
+// client address. Once a key exceeds its allowed attempts it is locked for
+// an exponentially growing duration. Locking an existing account emails the
+// owner an unlock link.
+func recordLoginFailure(kit *kit.Kit, email string, user User) error {
+	ip := clientIP(kit.Request)
+	var userID *uint
+	if user.ID > 0 {
+		userID = &user.ID
+		recordActivity(kit, user.ID, ActivityLoginFailed, "")
+	}
+
+	accountAttempt, created, err := throttleLoginFailure(accountThrottleKey(email), config.Auth.LoginMaxAttempts, userID, ip)
+	if err != nil {
+		return err
+	}
+	if created && userID != nil {
+		token, err := createUserToken(user.ID, TokenPurposeAccountUnlock, unlockTokenExpiry())
+		if err != nil {
+			return err
+		}
+		event.Emit(AccountLockedEvent, UserWithUnlockToken{
+			User:        user,
+			Token:       token,
+			LockedUntil: accountAttempt.LockedUntil.Time,
+		})
+	}
+
+	_, _, err = throttleLoginFailure(ipThrottleKey(ip), config.Auth.LoginMaxAttemptsPerIP, nil, ip)
+	return err
+}
+
+// clearLoginFailures resets the counters of the given keys.
+func clearLoginFailures(keys ...string) error {
+	return db.Get().Unscoped().Where("throttle_key IN ?", keys).Delete(&LoginAttempt{}).Error
+}
+
+// throttleLoginFailure counts a failure of the key and records the lockout
+// once it is locked, in one transaction so that concurrent failures are all
+// counted and only the first of them creates the lockout. It returns true
+// if a new lockout was created.
+func throttleLoginFailure(key string, maxAttempts int, userID *uint, ip string) (LoginAttempt, bool, error) {
+	var (
+		attempt LoginAttempt
+		created bool
+	)
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		var (
+			locked bool
+			err    error
+		)
+		attempt, locked, err = countLoginFailure(tx, key, maxAttempts)
+		if err != nil || !locked {
+			return err
+		}
+		created, err = recordLockout(tx, attempt, userID, ip)
+		return err
+	})
+	return attempt, created, err
+}
+
+// countLoginFailure increments the failures of the key and locks it once
+// maxAttempts is reached. Failures are forgotten after a quiet period.
+// The counter and the lock are updated by a single statement, so parallel
+// requests can't overwrite each other's failures.
+func countLoginFailure(tx *gorm.DB, key string, maxAttempts int) (LoginAttempt, bool, error) {
+	var attempt LoginAttempt
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&LoginAttempt{Key: key}).Error
+	if err != nil {
+		return attempt, false, err
+	}
+
+	now := time.Now()
+	failures := "CASE WHEN (locked_until IS NULL OR locked_until < @now) AND updated_at < @windowStart " +
+		"THEN 1 ELSE failures + 1 END"
+	lockedUntil, args := lockoutEnd(failures, maxAttempts, now)
+	args["now"] = now
+	args["windowStart"] = now.Add(-loginAttemptWindow())
+	args["key"] = key
+	err = tx.Raw("UPDATE login_attempts SET failures = "+failures+", locked_until = "+lockedUntil+
+		", updated_at = @now WHERE throttle_key = @key RETURNING *", args).
+		Scan(&attempt).Error
+	if err != nil {
+		return attempt, false, err
+	}
+	return attempt, attempt.Failures >= maxAttempts, nil
+}
+
+// lockoutEnd returns an SQL expression for the end of the lockout after
+// the failures of the given expression and the times it binds. The times
+// are computed here so they are stored like any other time column.
+func lockoutEnd(failures string, maxAttempts int, now time.Time) (string, map[string]any) {
+	args := map[string]any{}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CASE WHEN %s < %d THEN locked_until", failures, maxAttempts)
+	limit := lockoutDuration(math.MaxInt)
+	for excess := 0; ; excess++ {
+		name := fmt.Sprintf("lockedUntil%d", excess)
+		duration := lockoutDuration(excess)
+		args[name] = now.Add(duration)
+		// The duration stops growing at the limit, or stays zero without a
+		// base lockout.
+		if duration >= limit || duration == 0 {
+			fmt.Fprintf(&b, " ELSE @%s END", name)
+			return b.String(), args
+		}
+		fmt.Fprintf(&b, " WHEN %s = %d THEN @%s", failures, maxAttempts+excess, name)
+	}
+}
+
+// recordLockout creates a lockout record for the locked attempt, or extends
+// the active one, inside the given transaction. It returns true if a new
+// record was created.
+func recordLockout(tx *gorm.DB, attempt LoginAttempt, userID *uint, ip string) (bool, error) {
+	var lockout AccountLockout
+	err := tx.
+		Where("throttle_key = ? AND unlocked_at IS NULL AND locked_until > ?", attempt.Key, time.Now()).
+		Find(&lockout).Error
+	if err != nil {
+		return false, err
+	}
+	created := lockout.ID == 0
+	lockout.Key = attempt.Key
+	lockout.UserID = userID
+	lockout.IPAddress = ip
+	lockout.Failures = attempt.Failures
+	lockout.LockedUntil = attempt.LockedUntil.Time
+	return created, tx.Save(&lockout).Error
+}
+
+// unlockAccount lifts the lockouts of the given user and resets the
+// failures of their email, phone and the given address inside the given
+// transaction.
+func unlockAccount(tx *gorm.DB, user User, ip string) error {
+	err := tx.Model(&AccountLockout{}).
+		Where("(user_id = ? OR throttle_key = ?) AND unlocked_at IS NULL", user.ID, ipThrottleKey(ip)).
+		Update("unlocked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	keys := []string{accountThrottleKey(user.Email), ipThrottleKey(ip)}
+	if len(user.Phone) > 0 {
+		keys = append(keys, accountThrottleKey(user.Phone))
+	}
+	return tx.Unscoped().
+		Where("throttle_key IN ?", keys).
+		Delete(&LoginAttempt{}).Error
+}
+
+// lockoutDuration doubles the lockout for every failure after the allowed
+// attempts, up to SUPERKIT_AUTH_LOCKOUT_MAX_MINUTES.
+func lockoutDuration(excess int) time.Duration {
+	base := time.Minute * time.Duration(config.Auth.LockoutMinutes)
+	limit := time.Minute * time.Duration(config.Auth.LockoutMaxMinutes)
+	// Compare before converting, a large excess overflows a Duration.
+	duration := float64(base) * math.Pow(2, float64(excess))
+	if duration >= float64(limit) {
+		return limit
+	}
+	return time.Duration(duration)
+}
+
+func loginAttemptWindow() time.Duration {
+	return time.Minute * time.Duration(config.Auth.LoginAttemptWindowInMinutes)
+}
+
+func unlockTokenExpiry() time.Duration {
+	return time.Hour * time.Duration(config.Auth.UnlockExpiryInHours)
+}
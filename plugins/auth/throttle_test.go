@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"gothstack/app/conf"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	config = &conf.Config{Auth: conf.Auth{LockoutMinutes: 1, LockoutMaxMinutes: 60}}
+	tests := []struct {
+		excess int
+		want   time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{5, 32 * time.Minute},
+		{6, 60 * time.Minute},
+		{1000, 60 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := lockoutDuration(tt.excess); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.excess, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleKeys(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{accountThrottleKey("User@Example.com"), "account:user@example.com"},
+		{accountThrottleKey("  user@example.com "), "account:user@example.com"},
+		{ipThrottleKey("203.0.113.7"), "ip:203.0.113.7"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("key = %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestCountLoginFailure(t *testing.T) {
+	setupTestDB(t)
+	key := accountThrottleKey("user@example.com")
+
+	// The key is locked from the third failure on, twice as long for every
+	// further failure.
+	tests := []struct {
+		failures int
+		locked   bool
+		lockFor  time.Duration
+	}{
+		{1, false, 0},
+		{2, false, 0},
+		{3, true, time.Minute},
+		{4, true, 2 * time.Minute},
+		{5, true, 4 * time.Minute},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		attempt, locked, err := countLoginFailure(db.Get(), key, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempt.Failures != tt.failures || locked != tt.locked {
+			t.Fatalf("failure %d: got %d failures, locked %v, want locked %v",
+				tt.failures, attempt.Failures, locked, tt.locked)
+		}
+		if !tt.locked {
+			continue
+		}
+		lockFor := attempt.LockedUntil.Time.Sub(start)
+		if lockFor < tt.lockFor || lockFor > tt.lockFor+time.Second {
+			t.Errorf("failure %d: locked for %v, want %v", tt.failures, lockFor, tt.lockFor)
+		}
+		if _, locked, err := loginLockedUntil(key); err != nil || !locked {
+			t.Errorf("failure %d: loginLockedUntil = %v, %v, want locked", tt.failures, locked, err)
+		}
+	}
+
+	if _, locked, err := loginLockedUntil(accountThrottleKey("other@example.com")); err != nil || locked {
+		t.Errorf("loginLockedUntil of another key = %v, %v, want unlocked", locked, err)
+	}
+}
+
+func TestCountLoginFailureForgetsOldFailures(t *testing.T) {
+	setupTestDB(t)
+	key := ipThrottleKey("203.0.113.7")
+	for range 2 {
+		if _, _, err := countLoginFailure(db.Get(), key, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Age the failures past the window.
+	err := db.Get().Model(&LoginAttempt{}).
+		Where("throttle_key = ?", key).
+		UpdateColumn("updated_at", time.Now().Add(-2*loginAttemptWindow())).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempt, locked, err := countLoginFailure(db.Get(), key, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Failures != 1 || locked {
+		t.Errorf("got %d failures, locked %v, want 1 failure and unlocked", attempt.Failures, locked)
+	}
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "user@example.com")
+
+	lockedEvents := make(chan UserWithUnlockToken, 10)
+	sub := event.Subscribe(AccountLockedEvent, func(ctx context.Context, e any) {
+		lockedEvents <- e.(UserWithUnlockToken)
+	})
+	t.Cleanup(func() { event.Unsubscribe(sub) })
+
+	newKit := func(ip string) *kit.Kit {
+		r := httptest.NewRequest("POST", "/login", nil)
+		r.RemoteAddr = ip + ":1234"
+		r = r.WithContext(context.WithValue(r.Context(), kit.AuthKey{}, Auth{}))
+		return &kit.Kit{Response: httptest.NewRecorder(), Request: r}
+	}
+
+	// The account allows 3 attempts, the address 5, see setupTestDB.
+	tests := []struct {
+		name          string
+		ip            string
+		email         string
+		user          User
+		accountLocked bool
+		ipLocked      bool
+	}{
+		{"first failure", "203.0.113.1", user.Email, user, false, false},
+		{"second failure", "203.0.113.2", user.Email, user, false, false},
+		{"third failure locks the account", "203.0.113.3", user.Email, user, true, false},
+		{"unknown accounts from one address", "198.51.100.1", "a@example.com", User{}, false, false},
+		{"", "198.51.100.1", "b@example.com", User{}, false, false},
+		{"", "198.51.100.1", "c@example.com", User{}, false, false},
+		{"", "198.51.100.1", "d@example.com", User{}, false, false},
+		{"fifth failure locks the address", "198.51.100.1", "e@example.com", User{}, false, true},
+	}
+	for _, tt := range tests {
+		if err := recordLoginFailure(newKit(tt.ip), tt.email, tt.user); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		_, accountLocked, err := loginLockedUntil(accountThrottleKey(tt.email))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ipLocked, err := loginLockedUntil(ipThrottleKey(tt.ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accountLocked != tt.accountLocked || ipLocked != tt.ipLocked {
+			t.Errorf("%s %s from %s: account locked %v, address locked %v, want %v, %v",
+				tt.name, tt.email, tt.ip, accountLocked, ipLocked, tt.accountLocked, tt.ipLocked)
+		}
+	}
+
+	select {
+	case e := <-lockedEvents:
+		if e.User.ID != user.ID || len(e.Token) == 0 {
+			t.Errorf("got locked event for user %d with token %q", e.User.ID, e.Token)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no AccountLockedEvent was emitted")
+	}
+
+	var lockouts []AccountLockout
+	if err := db.Get().Order("id").Find(&lockouts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 2 {
+		t.Fatalf("got %d lockouts, want 2", len(lockouts))
+	}
+	if lockouts[0].UserID == nil || *lockouts[0].UserID != user.ID {
+		t.Errorf("the account lockout is not linked to the user")
+	}
+	if lockouts[1].Key != ipThrottleKey("198.51.100.1") || lockouts[1].UserID != nil {
+		t.Errorf("got address lockout %q for user %v", lockouts[1].Key, lockouts[1].UserID)
+	}
+
+	// A further failure extends the lockout instead of creating another one
+	// and emailing the user again.
+	if err := recordLoginFailure(newKit("203.0.113.4"), user.Email, user); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := db.Get().Model(&AccountLockout{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("got %d account lockouts, want 1", count)
+	}
+	select {
+	case <-lockedEvents:
+		t.Error("a second AccountLockedEvent was emitted")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := unlockAccount(db.Get(), user, "203.0.113.4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, locked, err := loginLockedUntil(accountThrottleKey(user.Email)); err != nil || locked {
+		t.Errorf("after unlockAccount: loginLockedUntil = %v, %v, want unlocked", locked, err)
+	}
+	var active int64
+	err := db.Get().Model(&AccountLockout{}).
+		Where("user_id = ? AND unlocked_at IS NULL", user.ID).
+		Count(&active).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != 0 {
+		t.Errorf("got %d active lockouts after unlockAccount, want 0", active)
+	}
+}
+
+func TestRecordLoginFailureInParallel(t *testing.T) {
+	setupConcurrentTestDB(t)
+	user := createTestUser(t, "user@example.com")
+
+	lockedEvents := make(chan UserWithUnlockToken, 20)
+	sub := event.Subscribe(AccountLockedEvent, func(ctx context.Context, e any) {
+		lockedEvents <- e.(UserWithUnlockToken)
+	})
+	t.Cleanup(func() { event.Unsubscribe(sub) })
+
+	const n = 20
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			r := httptest.NewRequest("POST", "/login", nil)
+			r.RemoteAddr = fmt.Sprintf("203.0.113.%d:1234", i)
+			r = r.WithContext(context.WithValue(r.Context(), kit.AuthKey{}, Auth{}))
+			errs <- recordLoginFailure(&kit.Kit{Response: httptest.NewRecorder(), Request: r}, user.Email, user)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every failure is counted and the account is locked once.
+	var attempt LoginAttempt
+	if err := db.Get().Where("throttle_key = ?", accountThrottleKey(user.Email)).First(&attempt).Error; err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Failures != n {
+		t.Errorf("got %d failures, want %d", attempt.Failures, n)
+	}
+	if _, locked, err := loginLockedUntil(accountThrottleKey(user.Email)); err != nil || !locked {
+		t.Errorf("loginLockedUntil = %v, %v, want locked", locked, err)
+	}
+	var count int64
+	if err := db.Get().Model(&AccountLockout{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("got %d account lockouts, want 1", count)
+	}
+	select {
+	case <-lockedEvents:
+	case <-time.After(time.Second):
+		t.Fatal("no AccountLockedEvent was emitted")
+	}
+	select {
+	case <-lockedEvents:
+		t.Error("a second AccountLockedEvent was emitted")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"gothstack/app/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Purposes of single-use user tokens.
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeMagicLink     = "magic_link"
+	TokenPurposeAccountUnlock = "account_unlock"
+)
+
+var errInvalidToken = errors.New("invalid or expired token")
+
+// UserToken is a single-use token that is mailed to a user. Only the
+// SHA-256 hash of the token is stored.
+type UserToken struct {
+	gorm.Model
+
+	UserID    uint
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	User      User
+}
+
+// createUserToken creates a new token for the given purpose and returns it
+// in plain text. Previously issued unused tokens for the same purpose
+// are revoked.
+func createUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Delete(&UserToken{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	return token, err
+}
+
+// findUserToken returns the unused and unexpired token matching the
+// given plain text token without consuming it.
+func findUserToken(tx *gorm.DB, purpose, token string) (UserToken, error) {
+	var userToken UserToken
+	err := tx.Preload("User").
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+			hashToken(token), purpose, time.Now()).
+		First(&userToken).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return userToken, errInvalidToken
+	}
+	return userToken, err
+}
+
+// consumeUserToken marks the token as used inside the given transaction
+// and returns it. A token can only be consumed once.
+func consumeUserToken(tx *gorm.DB, purpose, token string) (UserToken, error) {
+	userToken, err := findUserToken(tx, purpose, token)
+	if err != nil {
+		return userToken, err
+	}
+	result := tx.Model(&UserToken{}).
+		Where("id = ? AND used_at IS NULL", userToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return userToken, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return userToken, errInvalidToken
+	}
+	return userToken, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
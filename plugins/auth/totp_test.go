@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"gothstack/app/db"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the test vectors in RFC 6238 appendix B,
+// "12345678901234567890" encoded in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists 8 digit codes, these are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := totpCode(rfcSecret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCode at %d: %v", tt.unix, err)
+		}
+		if code != tt.code {
+			t.Errorf("totpCode at %d = %q, want %q", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestTOTPCodeLowercaseSecret(t *testing.T) {
+	code, err := totpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 59/totpPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Errorf("totpCode = %q, want %q", code, "287082")
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := totpCode("not base32!", 1); err == nil {
+		t.Error("totpCode accepted an invalid secret")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	code := func(step int64) string {
+		code, err := totpCode(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(step), 0, step, true},
+		{"previous step within skew", code(step - 1), 0, step - 1, true},
+		{"next step within skew", code(step + 1), 0, step + 1, true},
+		{"two steps behind", code(step - 2), 0, 0, false},
+		{"two steps ahead", code(step + 2), 0, 0, false},
+		{"spaces are ignored", " 050 471 ", 0, step, true},
+		{"replay of the same step", code(step), step, 0, false},
+		{"replay of an older step", code(step - 1), step - 1, 0, false},
+		{"newer step after an older one", code(step), step - 1, step, true},
+		{"too short", "05047", 0, 0, false},
+		{"too long", "0504711", 0, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+		{"empty", "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := validateTOTP(rfcSecret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("validateTOTP(%q, lastStep %d) = %d, %v, want %d, %v",
+					tt.code, tt.lastStep, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := totpURI("superkit", "user@example.com", rfcSecret)
+	want := "otpauth://totp/superkit:user@example.com?algorithm=SHA1&digits=6&issuer=superkit&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("totpURI = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestVerifySecondFactorRecoveryCode(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "user@example.com")
+	codes, err := replaceRecoveryCodes(db.Get(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"unused code", codes[0], true},
+		{"used code", codes[0], false},
+		{"uppercase with spaces", "  " + strings.ToUpper(codes[1]) + " ", true},
+		{"unknown code", "aaaaa-aaaaa", false},
+		{"TOTP code without enrollment", "123456", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifySecondFactor(user, tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("verifySecondFactor(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+
+	// Regenerating the codes invalidates the old ones.
+	if _, err := replaceRecoveryCodes(db.Get(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := verifySecondFactor(user, codes[2]); err != nil || ok {
+		t.Errorf("verifySecondFactor accepted a replaced code: %v, %v", ok, err)
+	}
+}
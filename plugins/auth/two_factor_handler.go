@@ -0,0 +1,424 @@
+package auth
+
+import (
+	"errors"
+	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	pendingUserIDKey    = "pendingUserID"
+	pendingExpiresAtKey = "pendingExpiresAt"
+	pendingLoginExpiry  = 10 * time.Minute
+	recoveryCodeCount   = 10
+)
+
+var twoFactorSchema = v.Schema{
+	"code": v.Rules(v.Required),
+}
+
+// HandleTwoFactorIndex shows the second login step. Users whose role
+// requires two-factor authentication but who have not enrolled yet are
+// asked to enroll first.
+func HandleTwoFactorIndex(kit *kit.Kit) error {
+	user, ok, err := pendingTwoFactorUser(kit)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return kit.Redirect(http.StatusSeeOther, "/login")
+	}
+	if user.TwoFactorEnabled() {
+		return kit.Render(TwoFactorIndex(TwoFactorFormValues{}))
+	}
+	enrollment, err := startTwoFactorEnrollment(user, "/login/2fa/setup")
+	if err != nil {
+		return err
+	}
+	return kit.Render(TwoFactorSetupIndex(enrollment, true))
+}
+
+// HandleTwoFactorCreate verifies the TOTP or recovery code of the second
+// login step and creates the session.
+func HandleTwoFactorCreate(kit *kit.Kit) error {
+	user, ok, err := pendingTwoFactorUser(kit)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return kit.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	var values TwoFactorFormValues
+	errors, ok := v.Request(kit.Request, &values, twoFactorSchema)
+	if !ok {
+		return kit.Render(TwoFactorForm(values, errors))
+	}
+	lockedUntil, locked, err := loginLockedUntil(accountThrottleKey(user.Email), ipThrottleKey(clientIP(kit.Request)))
+	if err != nil {
+		return err
+	}
+	if locked {
+		errors.Add("code", lockoutMessage(lockedUntil))
+		return kit.Render(TwoFactorForm(values, errors))
+	}
+	valid, err := verifySecondFactor(user, values.Code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		if err := recordLoginFailure(kit, user.Email, user); err != nil {
+			return err
+		}
+		errors.Add("code", "invalid authentication code")
+		return kit.Render(TwoFactorForm(values, errors))
+	}
+
+	if err := completeTwoFactor(kit, user); err != nil {
+		return err
+	}
+	return kit.Redirect(http.StatusSeeOther, loginRedirectURL(kit))
+}
+
+// HandleTwoFactorLoginSetup confirms the mandatory enrollment of a pending
+// user and logs them in.
+func HandleTwoFactorLoginSetup(kit *kit.Kit) error {
+	user, ok, err := pendingTwoFactorUser(kit)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return kit.Redirect(http.StatusSeeOther, "/login")
+	}
+	enrollment := TwoFactorEnrollment{Action: "/login/2fa/setup"}
+
+	var values TwoFactorFormValues
+	errors, ok := v.Request(kit.Request, &values, twoFactorSchema)
+	if !ok {
+		return kit.Render(TwoFactorSetupForm(enrollment, values, errors))
+	}
+	// The enrollment finishes the login, so it is throttled like the
+	// second login step.
+	lockedUntil, locked, err := loginLockedUntil(accountThrottleKey(user.Email), ipThrottleKey(clientIP(kit.Request)))
+	if err != nil {
+		return err
+	}
+	if locked {
+		errors.Add("code", lockoutMessage(lockedUntil))
+		return kit.Render(TwoFactorSetupForm(enrollment, values, errors))
+	}
+	codes, ok, err := confirmTwoFactorEnrollment(user.ID, values.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := recordLoginFailure(kit, user.Email, user); err != nil {
+			return err
+		}
+		errors.Add("code", "invalid authentication code")
+		return kit.Render(TwoFactorSetupForm(enrollment, values, errors))
+	}
+	recordActivity(kit, user.ID, ActivityTwoFactorEnabled, "")
+
+	if err := completeTwoFactor(kit, user); err != nil {
+		return err
+	}
+	return kit.Render(RecoveryCodes(codes, loginRedirectURL(kit)))
+}
+
+// HandleTwoFactorSetupIndex starts a voluntary enrollment from the profile.
+func HandleTwoFactorSetupIndex(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	var user User
+	if err := db.Get().First(&user, auth.UserID).Error; err != nil {
+		return err
+	}
+	if user.TwoFactorEnabled() {
+		return kit.Redirect(http.StatusSeeOther, "/profile")
+	}
+	enrollment, err := startTwoFactorEnrollment(user, "/profile/2fa/setup")
+	if err != nil {
+		return err
+	}
+	return kit.Render(TwoFactorSetupIndex(enrollment, false))
+}
+
+// HandleTwoFactorSetupCreate confirms a voluntary enrollment.
+func HandleTwoFactorSetupCreate(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	enrollment := TwoFactorEnrollment{Action: "/profile/2fa/setup"}
+
+	var values TwoFactorFormValues
+	errors, ok := v.Request(kit.Request, &values, twoFactorSchema)
+	if !ok {
+		return kit.Render(TwoFactorSetupForm(enrollment, values, errors))
+	}
+	codes, ok, err := confirmTwoFactorEnrollment(auth.UserID, values.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		errors.Add("code", "invalid authentication code")
+		return kit.Render(TwoFactorSetupForm(enrollment, values, errors))
+	}
+	recordActivity(kit, auth.UserID, ActivityTwoFactorEnabled, "")
+	return kit.Render(RecoveryCodes(codes, "/profile"))
+}
+
+// HandleRecoveryCodesCreate replaces the recovery codes of the
+// authenticated user after verifying a TOTP code.
+func HandleRecoveryCodesCreate(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	var user User
+	if err := db.Get().First(&user, auth.UserID).Error; err != nil {
+		return err
+	}
+	section := TwoFactorSectionData{
+		Enabled:  user.TwoFactorEnabled(),
+		Required: twoFactorRequired(user.Role),
+	}
+
+	var values TwoFactorFormValues
+	errors, ok := v.Request(kit.Request, &values, twoFactorSchema)
+	if !ok {
+		return kit.Render(TwoFactorSection(section, errors))
+	}
+	valid, err := verifyTOTP(user, values.Code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		errors.Add("code", "invalid authentication code")
+		return kit.Render(TwoFactorSection(section, errors))
+	}
+
+	var codes []string
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return kit.Render(RecoveryCodes(codes, "/profile"))
+}
+
+// HandleTwoFactorDisable disables two-factor authentication for the
+// authenticated user, unless their role requires it.
+func HandleTwoFactorDisable(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	var user User
+	if err := db.Get().First(&user, auth.UserID).Error; err != nil {
+		return err
+	}
+	section := TwoFactorSectionData{
+		Enabled:  user.TwoFactorEnabled(),
+		Required: twoFactorRequired(user.Role),
+	}
+
+	var values TwoFactorFormValues
+	errors, ok := v.Request(kit.Request, &values, twoFactorSchema)
+	if !ok {
+		return kit.Render(TwoFactorSection(section, errors))
+	}
+	if section.Required {
+		errors.Add("code", "two-factor authentication is required for your role")
+		return kit.Render(TwoFactorSection(section, errors))
+	}
+	valid, err := verifySecondFactor(user, values.Code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		errors.Add("code", "invalid authentication code")
+		return kit.Render(TwoFactorSection(section, errors))
+	}
+
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	recordActivity(kit, user.ID, ActivityTwoFactorDisabled, "")
+
+	section.Enabled = false
+	return kit.Render(TwoFactorSection(section, v.Errors{}))
+}
+
+// twoFactorRequired returns true if users with the given role must use
+// two-factor authentication. The roles are configured as a comma separated
+// list in SUPERKIT_AUTH_2FA_REQUIRED_ROLES.
+func twoFactorRequired(role string) bool {
+	return slices.Contains(config.Auth.TwoFactorRequiredRoles, role)
+}
+
+// twoFactorMissing returns true if the role of the user requires
+// two-factor authentication but the user has not enrolled, e.g. after
+// their role changed. Their sessions and API tokens are refused, so they
+// have to log in again and enroll.
+func twoFactorMissing(user User) bool {
+	return twoFactorRequired(user.Role) && !user.TwoFactorEnabled()
+}
+
+// beginTwoFactor remembers the user that passed the password check and
+// sends them to the second login step.
+func beginTwoFactor(kit *kit.Kit, user User) error {
+	sess := kit.GetSession(userSessionName)
+	sess.Values[pendingUserIDKey] = user.ID
+	sess.Values[pendingExpiresAtKey] = time.Now().Add(pendingLoginExpiry).Unix()
+	if err := sess.Save(kit.Request, kit.Response); err != nil {
+		return err
+	}
+	return kit.Redirect(http.StatusSeeOther, "/login/2fa")
+}
+
+// pendingTwoFactorUser returns the user waiting for the second login step.
+func pendingTwoFactorUser(kit *kit.Kit) (User, bool, error) {
+	var user User
+	sess := kit.GetSession(userSessionName)
+	userID, ok := sess.Values[pendingUserIDKey].(uint)
+	expiresAt, _ := sess.Values[pendingExpiresAtKey].(int64)
+	if !ok || time.Now().Unix() > expiresAt {
+		return user, false, nil
+	}
+	err := db.Get().First(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, false, nil
+	}
+	return user, err == nil, err
+}
+
+// completeTwoFactor finishes the login of the pending user.
+func completeTwoFactor(kit *kit.Kit, user User) error {
+	sess := kit.GetSession(userSessionName)
+	delete(sess.Values, pendingUserIDKey)
+	delete(sess.Values, pendingExpiresAtKey)
+	if err := clearLoginFailures(accountThrottleKey(user.Email)); err != nil {
+		return err
+	}
+	return createSession(kit, user)
+}
+
+// startTwoFactorEnrollment stores a new unconfirmed TOTP secret for the
+// user, or reuses the one stored by an earlier visit, so reloading the page
+// doesn't invalidate an authenticator app that was already set up.
+func startTwoFactorEnrollment(user User, action string) (TwoFactorEnrollment, error) {
+	if len(user.TOTPSecret) == 0 {
+		secret, err := generateTOTPSecret()
+		if err != nil {
+			return TwoFactorEnrollment{}, err
+		}
+		// Only the first of concurrent requests stores its secret.
+		err = db.Get().Model(&User{}).
+			Where("id = ? AND totp_enabled_at IS NULL AND (totp_secret IS NULL OR totp_secret = '')", user.ID).
+			Update("totp_secret", secret).Error
+		if err != nil {
+			return TwoFactorEnrollment{}, err
+		}
+		if err := db.Get().First(&user, user.ID).Error; err != nil {
+			return TwoFactorEnrollment{}, err
+		}
+	}
+	secret := user.TOTPSecret
+	issuer := config.Auth.TOTPIssuer
+	return TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totpURI(issuer, user.Email, secret),
+		Action: action,
+	}, nil
+}
+
+// confirmTwoFactorEnrollment enables two-factor authentication if the code
+// matches the unconfirmed secret and returns the new recovery codes.
+func confirmTwoFactorEnrollment(userID uint, code string) ([]string, bool, error) {
+	var user User
+	if err := db.Get().First(&user, userID).Error; err != nil {
+		return nil, false, err
+	}
+	if user.TwoFactorEnabled() || len(user.TOTPSecret) == 0 {
+		return nil, false, nil
+	}
+	step, ok := validateTOTP(user.TOTPSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, false, nil
+	}
+
+	var codes []string
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err == nil, err
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func verifySecondFactor(user User, code string) (bool, error) {
+	valid, err := verifyTOTP(user, code)
+	if err != nil || valid {
+		return valid, err
+	}
+	code = strings.ToLower(strings.TrimSpace(code))
+	result := db.Get().Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(code)).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// verifyTOTP checks the TOTP code and records its time step so the same
+// code can't be replayed.
+func verifyTOTP(user User, code string) (bool, error) {
+	if !user.TwoFactorEnabled() {
+		return false, nil
+	}
+	step, ok := validateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	result := db.Get().Model(&User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		err := tx.Create(&RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(code),
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
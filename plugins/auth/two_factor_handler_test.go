@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartTwoFactorEnrollmentReusesSecret(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "user@example.com")
+
+	first, err := startTwoFactorEnrollment(user, "/profile/2fa/setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Secret) == 0 {
+		t.Fatal("no secret was generated")
+	}
+	// A stale copy of the user, like a concurrent request would have, gets
+	// the stored secret too.
+	second, err := startTwoFactorEnrollment(user, "/profile/2fa/setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get().First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	third, err := startTwoFactorEnrollment(user, "/profile/2fa/setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Secret != first.Secret || third.Secret != first.Secret || user.TOTPSecret != first.Secret {
+		t.Errorf("got secrets %q, %q and %q, stored %q, want the first one every time",
+			first.Secret, second.Secret, third.Secret, user.TOTPSecret)
+	}
+	if !strings.Contains(third.URI, first.Secret) {
+		t.Errorf("URI %q doesn't contain the secret", third.URI)
+	}
+}
+
+func TestAuthenticateWithoutRequiredTwoFactor(t *testing.T) {
+	setupTestDB(t)
+	if err := kit.Configure("test", strings.Repeat("s", 32)); err != nil {
+		t.Fatal(err)
+	}
+	config.Auth.TwoFactorRequiredRoles = []string{RoleAdmin}
+	user := createTestUser(t, "admin@example.com")
+
+	session := Session{UserID: user.ID, Token: "session-token", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Get().Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	k := &kit.Kit{Response: w, Request: httptest.NewRequest("GET", "/", nil)}
+	sess := k.GetSession(userSessionName)
+	sess.Values["sessionToken"] = session.Token
+	if err := sess.Save(k.Request, k.Response); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	apiToken, err := createAPIToken(user.ID, "test", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// authenticate returns whether the session and the API token of the
+	// user are accepted.
+	authenticate := func() (bool, bool) {
+		r := httptest.NewRequest("GET", "/", nil)
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), kit.AuthKey{}, Auth{}))
+		bySession, err := AuthenticateUser(&kit.Kit{Response: httptest.NewRecorder(), Request: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+apiToken)
+		byToken, err := AuthenticateToken(&kit.Kit{Response: httptest.NewRecorder(), Request: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bySession.Check(), byToken.Check()
+	}
+
+	tests := []struct {
+		name    string
+		updates map[string]any
+		want    bool
+	}{
+		{"role without 2FA", map[string]any{"role": RoleUser}, true},
+		{"role changed to one requiring 2FA", map[string]any{"role": RoleAdmin}, false},
+		{"after enrolling", map[string]any{"totp_enabled_at": sql.NullTime{Time: time.Now(), Valid: true}}, true},
+	}
+	for _, tt := range tests {
+		if err := db.Get().Model(&User{}).Where("id = ?", user.ID).Updates(tt.updates).Error; err != nil {
+			t.Fatal(err)
+		}
+		bySession, byToken := authenticate()
+		if bySession != tt.want || byToken != tt.want {
+			t.Errorf("%s: session accepted %v, token accepted %v, want %v", tt.name, bySession, byToken, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"database/sql"
 	"gothstack/app/db"
+	"slices"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -13,6 +14,13 @@ import (
 const (
 	UserSignupEvent         = "auth.signup"
 	ResendVerificationEvent = "auth.resend.verification"
+	PasswordResetEvent      = "auth.password.reset"
+	AccountLockedEvent      = "auth.account.locked"
+	MagicLinkEvent          = "auth.magic.link"
+	EmailChangeEvent        = "auth.email.change"
+	EmailChangeNoticeEvent  = "auth.email.change.notice"
+	AccountDeletedEvent     = "auth.account.deleted"
+	InviteCreatedEvent      = "auth.invite.created"
 )
 
 // UserWithVerificationToken is a struct that will be sent over the
@@ -22,31 +30,128 @@ type UserWithVerificationToken struct {
 	Token string
 }
 
+// UserWithResetToken is a struct that will be sent over the
+// auth.password.reset event. It holds the User struct and the reset token string.
+type UserWithResetToken struct {
+	User  User
+	Token string
+}
+
+// UserWithLoginToken is a struct that will be sent over the
+// auth.magic.link event. It holds the User struct and the login token string.
+type UserWithLoginToken struct {
+	User  User
+	Token string
+}
+
+// EmailChangeRequest is a struct that will be sent over the
+// auth.email.change event to confirm the new address, and over the
+// auth.email.change.notice event without a token to notify the old address.
+type EmailChangeRequest struct {
+	User     User
+	NewEmail string
+	Token    string
+}
+
+// InviteWithCode is a struct that will be sent over the
+// auth.invite.created event for invites tied to an email. It holds the
+// Invite struct and the invite code string.
+type InviteWithCode struct {
+	Invite Invite
+	Code   string
+}
+
+// UserWithUnlockToken is a struct that will be sent over the
+// auth.account.locked event. It holds the User struct, the unlock token string
+// and the time until which the account is locked.
+type UserWithUnlockToken struct {
+	User        User
+	Token       string
+	LockedUntil time.Time
+}
+
 type Auth struct {
-	UserID   uint
-	Email    string
-	LoggedIn bool
+	UserID      uint
+	Email       string
+	Role        string
+	Permissions []string
+	LoggedIn    bool
+	// Method is AuthMethodSession for cookie sessions and AuthMethodToken
+	// for requests with an API token.
+	Method string
 }
 
 func (auth Auth) Check() bool {
 	return auth.LoggedIn
 }
 
+// IsTokenRequest returns true if the request was authenticated with an
+// API token instead of a cookie session.
+func (auth Auth) IsTokenRequest() bool {
+	return auth.LoggedIn && auth.Method == AuthMethodToken
+}
+
+// Scoped returns true for API token requests, which kit only lets through
+// routes that require one of the scopes of the token.
+func (auth Auth) Scoped() bool {
+	return auth.IsTokenRequest()
+}
+
+// HasRole returns true if the authenticated user has the given role. It is
+// always false for API token requests, which only get the permissions in
+// the scopes of the token.
+func (auth Auth) HasRole(role string) bool {
+	return auth.LoggedIn && auth.Method != AuthMethodToken && auth.Role == role
+}
+
+// Can returns true if the authenticated user's role grants the given permission.
+func (auth Auth) Can(permission string) bool {
+	return auth.LoggedIn && slices.Contains(auth.Permissions, permission)
+}
+
 type User struct {
 	gorm.Model
 
 	Email           string
 	FirstName       string
 	LastName        string
-	PasswordHash    string
+	PasswordHash    string `json:"-"`
 	Role            string
 	EmailVerifiedAt sql.NullTime
+	TOTPSecret      string       `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabledAt   sql.NullTime `gorm:"column:totp_enabled_at"`
+	TOTPLastStep    int64        `gorm:"column:totp_last_step" json:"-"`
+	Phone           string
+	PINHash         string `gorm:"column:pin_hash" json:"-"`
+	DisabledAt      sql.NullTime
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
 
-func createUserFromFormValues(values SignupFormValues) (User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(values.Password), bcrypt.DefaultCost)
+// TwoFactorEnabled returns true if the user has confirmed a TOTP enrollment.
+func (user User) TwoFactorEnabled() bool {
+	return user.TOTPEnabledAt.Valid
+}
+
+// Disabled returns true if an admin disabled the account.
+func (user User) Disabled() bool {
+	return user.DisabledAt.Valid
+}
+
+// RecoveryCode is a single-use code that can be used instead of a TOTP
+// code when the user lost access to their authenticator app.
+type RecoveryCode struct {
+	gorm.Model
+
+	UserID   uint
+	CodeHash string
+	UsedAt   sql.NullTime
+}
+
+// createUserFromFormValues creates the user that signed up. The invite,
+// if any, is used up in the same transaction.
+func createUserFromFormValues(values SignupFormValues, invite *Invite) (User, error) {
+	hash, err := hashPassword(values.Password)
 	if err != nil {
 		return User{}, err
 	}
@@ -54,11 +159,30 @@ func createUserFromFormValues(values SignupFormValues) (User, error) {
 		Email:        values.Email,
 		FirstName:    values.FirstName,
 		LastName:     values.LastName,
-		Role:         "user",
-		PasswordHash: string(hash),
+		Role:         RoleUser,
+		PasswordHash: hash,
+	}
+	if invite != nil {
+		user.Phone = invite.Phone
 	}
-	result := db.Get().Create(&user)
-	return user, result.Error
+	err = db.Get().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if invite == nil {
+			return nil
+		}
+		return useInvite(tx, *invite, user.ID)
+	})
+	return user, err
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
 }
 
 type Session struct {
@@ -73,6 +197,11 @@ type Session struct {
 	User      User
 }
 
+// deleteUserSessions logs the given user out of every device.
+func deleteUserSessions(tx *gorm.DB, userID uint) error {
+	return tx.Where("user_id = ?", userID).Delete(&Session{}).Error
+}
+
 // using goose to init table
 /* func initialize() {
 	db.Get().AutoMigrate(&Session{}, &User{})
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	userAdminPageSize      = 25
+	userAdminActivityLimit = 50
+)
+
+// User status filters of the admin users list.
+const (
+	UserStatusVerified   = "verified"
+	UserStatusUnverified = "unverified"
+	UserStatusDisabled   = "disabled"
+)
+
+// HandleUserAdminIndex lists users for admins with search, filters and
+// pagination. Htmx requests only get the table back.
+func HandleUserAdminIndex(kit *kit.Kit) error {
+	query := kit.Request.URL.Query()
+	filters := UserFilters{
+		Query:  strings.TrimSpace(query.Get("q")),
+		Role:   query.Get("role"),
+		Status: query.Get("status"),
+	}
+	filters.Page, _ = strconv.Atoi(query.Get("page"))
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+
+	list, err := searchUsers(filters)
+	if err != nil {
+		return err
+	}
+	return kit.RespondFragment(http.StatusOK, list, UserAdminIndex(list), UserAdminTable(list))
+}
+
+// HandleUserAdminShow shows the account, sessions and recent activity of
+// a user.
+func HandleUserAdminShow(kit *kit.Kit) error {
+	data, err := userAdminPageData(kit)
+	if err != nil {
+		return err
+	}
+	return kit.Render(UserAdminShow(data))
+}
+
+// HandleUserAdminRoleUpdate changes the role of a user.
+func HandleUserAdminRoleUpdate(kit *kit.Kit) error {
+	data, err := userAdminPageData(kit)
+	if err != nil {
+		return err
+	}
+	role := kit.FormValue("role")
+	switch {
+	case data.Self:
+		data.Error = "you can't change your own role"
+	case !slices.Contains(Roles(), role):
+		data.Error = "unknown role"
+	case role != data.User.Role:
+		if err := db.Get().Model(&data.User).Update("role", role).Error; err != nil {
+			return err
+		}
+		recordActivity(kit, data.User.ID, ActivityRoleChanged, fmt.Sprintf("%s to %s", data.User.Role, role))
+		data.Message = fmt.Sprintf("Role changed to %s.", role)
+	}
+	return kit.Render(UserAdminAccount(data))
+}
+
+// HandleUserAdminVerify marks the email of a user as verified.
+func HandleUserAdminVerify(kit *kit.Kit) error {
+	data, err := userAdminPageData(kit)
+	if err != nil {
+		return err
+	}
+	if !data.User.EmailVerifiedAt.Valid {
+		verifiedAt := sql.NullTime{Time: time.Now(), Valid: true}
+		if err := db.Get().Model(&data.User).Update("email_verified_at", verifiedAt).Error; err != nil {
+			return err
+		}
+		recordActivity(kit, data.User.ID, ActivityEmailVerified, "")
+		data.Message = "Email marked as verified."
+	}
+	return kit.Render(UserAdminAccount(data))
+}
+
+// HandleUserAdminDisable disables an account and signs it out everywhere.
+// API tokens are kept but rejected until the account is enabled again.
+func HandleUserAdminDisable(kit *kit.Kit) error {
+	data, err := userAdminPageData(kit)
+	if err != nil {
+		return err
+	}
+	if data.Self {
+		data.Error = "you can't disable your own account"
+		return kit.Render(UserAdminAccount(data))
+	}
+	if !data.User.Disabled() {
+		err := db.Get().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&data.User).Update("disabled_at", time.Now()).Error; err != nil {
+				return err
+			}
+			return deleteUserSessions(tx, data.User.ID)
+		})
+		if err != nil {
+			return err
+		}
+		recordActivity(kit, data.User.ID, ActivityAccountDisabled, "")
+		data.Message = "Account disabled and signed out everywhere."
+	}
+	return kit.Render(UserAdminAccount(data))
+}
+
+// HandleUserAdminEnable enables a disabled account again.
+func HandleUserAdminEnable(kit *kit.Kit) error {
+	data, err := userAdminPageData(kit)
+	if err != nil {
+		return err
+	}
+	if data.User.Disabled() {
+		if err := db.Get().Model(&data.User).Update("disabled_at", nil).Error; err != nil {
+			return err
+		}
+		recordActivity(kit, data.User.ID, ActivityAccountEnabled, "")
+		data.Message = "Account enabled."
+	}
+	return kit.Render(UserAdminAccount(data))
+}
+
+// HandleUserAdminPasswordReset mails a password reset link to the user.
+func HandleUserAdminPasswordReset(kit *kit.Kit) error {
+	data, err := userAdminPageData(kit)
+	if err != nil {
+		return err
+	}
+	token, err := createUserToken(data.User.ID, TokenPurposePasswordReset, passwordResetExpiry())
+	if err != nil {
+		return err
+	}
+	event.Emit(PasswordResetEvent, UserWithResetToken{
+		User:  data.User,
+		Token: token,
+	})
+	recordActivity(kit, data.User.ID, ActivityPasswordResetSent, "")
+	data.Message = fmt.Sprintf("Password reset link sent to %s.", data.User.Email)
+	return kit.Render(UserAdminAccount(data))
+}
+
+// HandleUserAdminSessionsDelete signs a user out on every device.
+func HandleUserAdminSessionsDelete(kit *kit.Kit) error {
+	data, err := userAdminPageData(kit)
+	if err != nil {
+		return err
+	}
+	if err := deleteUserSessions(db.Get(), data.User.ID); err != nil {
+		return err
+	}
+	recordActivity(kit, data.User.ID, ActivitySessionsRevoked, "")
+	return kit.Render(UserAdminSessionList(nil))
+}
+
+// userAdminPageData loads the user of the {id} URL parameter with their
+// sessions and recent activity.
+func userAdminPageData(kit *kit.Kit) (UserAdminPageData, error) {
+	auth := kit.Auth().(Auth)
+	var data UserAdminPageData
+	id, err := kit.URLParamID("id")
+	if err != nil {
+		return data, err
+	}
+	if err := db.Get().First(&data.User, id).Error; err != nil {
+		return data, err
+	}
+	data.Self = data.User.ID == auth.UserID
+	if data.Sessions, err = activeSessions(data.User.ID); err != nil {
+		return data, err
+	}
+	if data.Activities, err = recentActivity(data.User.ID, userAdminActivityLimit); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
+// searchUsers returns the page of users matching the filters.
+func searchUsers(filters UserFilters) (UserAdminList, error) {
+	list := UserAdminList{Filters: filters}
+	query := db.Get().Model(&User{})
+	if len(filters.Query) > 0 {
+		like := "%" + strings.ToLower(filters.Query) + "%"
+		query = query.Where(
+			"lower(email) LIKE ? OR lower(first_name || ' ' || last_name) LIKE ? OR phone LIKE ?",
+			like, like, like,
+		)
+	}
+	if slices.Contains(Roles(), filters.Role) {
+		query = query.Where("role = ?", filters.Role)
+	}
+	switch filters.Status {
+	case UserStatusVerified:
+		query = query.Where("email_verified_at IS NOT NULL AND disabled_at IS NULL")
+	case UserStatusUnverified:
+		query = query.Where("email_verified_at IS NULL AND disabled_at IS NULL")
+	case UserStatusDisabled:
+		query = query.Where("disabled_at IS NOT NULL")
+	}
+
+	if err := query.Count(&list.Total).Error; err != nil {
+		return list, err
+	}
+	list.Pages = max(1, int((list.Total+userAdminPageSize-1)/userAdminPageSize))
+	err := query.
+		Order("created_at desc").
+		Offset((filters.Page - 1) * userAdminPageSize).
+		Limit(userAdminPageSize).
+		Find(&list.Users).Error
+	return list, err
+}
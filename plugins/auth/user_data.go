@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"gothstack/app/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserDataHook lets a plugin take part in personal data exports and
+// account deletion for the data it stores about a user.
+type UserDataHook struct {
+	// Name is used as the file name of the exported data, e.g. "delivery"
+	// is exported as delivery.json.
+	Name string
+	// Export returns the data stored about the user. It must be
+	// serializable with encoding/json.
+	Export func(tx *gorm.DB, userID uint) (any, error)
+	// Erase removes or anonymizes the data stored about the user. It runs
+	// in the same transaction as the deletion of the account.
+	Erase func(tx *gorm.DB, userID uint) error
+}
+
+var userDataHooks []UserDataHook
+
+// RegisterUserDataHook registers a hook that is called when a user exports
+// their data or deletes their account. It replaces an earlier hook with
+// the same name, so plugins can register their hook on every Init.
+func RegisterUserDataHook(hook UserDataHook) {
+	for i, registered := range userDataHooks {
+		if registered.Name == hook.Name {
+			userDataHooks[i] = hook
+			return
+		}
+	}
+	userDataHooks = append(userDataHooks, hook)
+}
+
+// accountExport is the data the auth plugin holds about a user. Password,
+// PIN and TOTP hashes and secrets are left out on purpose.
+type accountExport struct {
+	ID                 uint             `json:"id"`
+	Email              string           `json:"email"`
+	FirstName          string           `json:"first_name"`
+	LastName           string           `json:"last_name"`
+	Phone              string           `json:"phone,omitempty"`
+	Role               string           `json:"role"`
+	EmailVerifiedAt    *time.Time       `json:"email_verified_at,omitempty"`
+	TwoFactorEnabledAt *time.Time       `json:"two_factor_enabled_at,omitempty"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+	Sessions           []sessionExport  `json:"sessions"`
+	Identities         []identityExport `json:"identities"`
+	APITokens          []apiTokenExport `json:"api_tokens"`
+	Lockouts           []lockoutExport  `json:"lockouts"`
+	Activity           []activityExport `json:"activity"`
+	Invites            []inviteExport   `json:"invites"`
+}
+
+type sessionExport struct {
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type identityExport struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiTokenExport struct {
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type lockoutExport struct {
+	IPAddress   string     `json:"ip_address"`
+	Failures    int        `json:"failures"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type inviteExport struct {
+	Email     string     `json:"email,omitempty"`
+	FirstName string     `json:"first_name,omitempty"`
+	LastName  string     `json:"last_name,omitempty"`
+	Phone     string     `json:"phone,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type activityExport struct {
+	Action    string    `json:"action"`
+	Detail    string    `json:"detail,omitempty"`
+	IPAddress string    `json:"ip_address"`
+	ByAdmin   bool      `json:"by_admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// writeUserDataArchive writes a zip archive with one JSON file for the
+// account and one for every registered hook.
+func writeUserDataArchive(zw *zip.Writer, userID uint) error {
+	account, err := exportAccount(db.Get(), userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(zw, "account.json", account); err != nil {
+		return err
+	}
+	for _, hook := range userDataHooks {
+		data, err := hook.Export(db.Get(), userID)
+		if err != nil {
+			return fmt.Errorf("export %s data: %w", hook.Name, err)
+		}
+		if err := writeJSONFile(zw, hook.Name+".json", data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeJSONFile(zw *zip.Writer, name string, data any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+func exportAccount(tx *gorm.DB, userID uint) (accountExport, error) {
+	var user User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return accountExport{}, err
+	}
+	account := accountExport{
+		ID:                 user.ID,
+		Email:              user.Email,
+		FirstName:          user.FirstName,
+		LastName:           user.LastName,
+		Phone:              user.Phone,
+		Role:               user.Role,
+		EmailVerifiedAt:    nullTime(user.EmailVerifiedAt.Time, user.EmailVerifiedAt.Valid),
+		TwoFactorEnabledAt: nullTime(user.TOTPEnabledAt.Time, user.TOTPEnabledAt.Valid),
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+		Sessions:           []sessionExport{},
+		Identities:         []identityExport{},
+		APITokens:          []apiTokenExport{},
+		Lockouts:           []lockoutExport{},
+		Activity:           []activityExport{},
+		Invites:            []inviteExport{},
+	}
+
+	var sessions []Session
+	if err := tx.Where("user_id = ?", userID).Order("created_at").Find(&sessions).Error; err != nil {
+		return account, err
+	}
+	for _, session := range sessions {
+		account.Sessions = append(account.Sessions, sessionExport{
+			IPAddress: session.IPAddress,
+			UserAgent: session.UserAgent,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		})
+	}
+
+	var identities []UserIdentity
+	if err := tx.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return account, err
+	}
+	for _, identity := range identities {
+		account.Identities = append(account.Identities, identityExport{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	var tokens []APIToken
+	if err := tx.Where("user_id = ?", userID).Order("created_at").Find(&tokens).Error; err != nil {
+		return account, err
+	}
+	for _, token := range tokens {
+		account.APITokens = append(account.APITokens, apiTokenExport{
+			Name:       token.Name,
+			Prefix:     token.TokenPrefix,
+			Scopes:     token.ScopeList(),
+			ExpiresAt:  nullTime(token.ExpiresAt.Time, token.ExpiresAt.Valid),
+			LastUsedAt: nullTime(token.LastUsedAt.Time, token.LastUsedAt.Valid),
+			CreatedAt:  token.CreatedAt,
+		})
+	}
+
+	var lockouts []AccountLockout
+	if err := tx.Where("user_id = ?", userID).Order("created_at").Find(&lockouts).Error; err != nil {
+		return account, err
+	}
+	for _, lockout := range lockouts {
+		account.Lockouts = append(account.Lockouts, lockoutExport{
+			IPAddress:   lockout.IPAddress,
+			Failures:    lockout.Failures,
+			LockedUntil: lockout.LockedUntil,
+			UnlockedAt:  nullTime(lockout.UnlockedAt.Time, lockout.UnlockedAt.Valid),
+			CreatedAt:   lockout.CreatedAt,
+		})
+	}
+
+	var activities []UserActivity
+	if err := tx.Where("user_id = ?", userID).Order("created_at").Find(&activities).Error; err != nil {
+		return account, err
+	}
+	for _, activity := range activities {
+		account.Activity = append(account.Activity, activityExport{
+			Action:    activity.Action,
+			Detail:    activity.Detail,
+			IPAddress: activity.IPAddress,
+			ByAdmin:   activity.ActorID != nil,
+			CreatedAt: activity.CreatedAt,
+		})
+	}
+
+	var invites []Invite
+	if err := userInvites(tx, user).Order("created_at").Find(&invites).Error; err != nil {
+		return account, err
+	}
+	for _, invite := range invites {
+		account.Invites = append(account.Invites, inviteExport{
+			Email:     invite.Email,
+			FirstName: invite.FirstName,
+			LastName:  invite.LastName,
+			Phone:     invite.Phone,
+			ExpiresAt: invite.ExpiresAt,
+			UsedAt:    nullTime(invite.UsedAt.Time, invite.UsedAt.Valid),
+			CreatedAt: invite.CreatedAt,
+		})
+	}
+	return account, nil
+}
+
+// userInvites selects the invites the user signed up with or that were
+// sent to their email. Invites the user created for others are not
+// theirs. Revoked invites are included.
+func userInvites(tx *gorm.DB, user User) *gorm.DB {
+	return tx.Unscoped().Model(&Invite{}).Where("used_by_id = ? OR (email <> '' AND email = ?)", user.ID, user.Email)
+}
+
+// deleteAccount anonymizes the user and removes everything that can be
+// used to sign in as them. The user row itself is kept, scrubbed and soft
+// deleted, so records of other plugins that reference it, like orders,
+// stay intact.
+func deleteAccount(user User) error {
+	return db.Get().Transaction(func(tx *gorm.DB) error {
+		for _, hook := range userDataHooks {
+			if err := hook.Erase(tx, user.ID); err != nil {
+				return fmt.Errorf("erase %s data: %w", hook.Name, err)
+			}
+		}
+
+		for _, model := range []any{&Session{}, &UserToken{}, &RecoveryCode{}, &UserIdentity{}, &APIToken{}, &UserActivity{}} {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		keys := []string{accountThrottleKey(user.Email)}
+		if len(user.Phone) > 0 {
+			keys = append(keys, accountThrottleKey(user.Phone))
+		}
+		err := tx.Unscoped().
+			Where("user_id = ? OR throttle_key IN ?", user.ID, keys).
+			Delete(&AccountLockout{}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("throttle_key IN ?", keys).Delete(&LoginAttempt{}).Error; err != nil {
+			return err
+		}
+		// Invites are kept so staff can still see which were used, but
+		// without the details of the invited person.
+		err = userInvites(tx, user).Updates(map[string]any{
+			"email":      "",
+			"first_name": "",
+			"last_name":  "",
+			"phone":      "",
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"email":             fmt.Sprintf("deleted-%d@invalid", user.ID),
+			"first_name":        "Deleted",
+			"last_name":         "User",
+			"password_hash":     "",
+			"email_verified_at": nil,
+			"totp_secret":       "",
+			"totp_enabled_at":   nil,
+			"totp_last_step":    0,
+			"phone":             nil,
+			"pin_hash":          nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&User{}, user.ID).Error
+	})
+}
+
+func nullTime(t time.Time, valid bool) *time.Time {
+	if !valid {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	v "gothstack/kit/validate"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var accountDeleteSchema = v.Schema{
+	"confirm": v.Rules(v.Required),
+}
+
+// HandleUserDataExport sends everything stored about the authenticated
+// user as a zip archive of JSON files.
+func HandleUserDataExport(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+
+	var buf bytes.Buffer
+	if err := writeUserDataArchive(zip.NewWriter(&buf), auth.UserID); err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("account-data-%s.zip", time.Now().Format("2006-01-02"))
+	kit.Response.Header().Set("Content-Type", "application/zip")
+	kit.Response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	kit.Response.Header().Set("Cache-Control", "no-store")
+	return kit.Bytes(http.StatusOK, buf.Bytes())
+}
+
+// HandleAccountDelete deletes the account of the authenticated user after
+// confirming it with their password, or their email for accounts without
+// a password.
+func HandleAccountDelete(kit *kit.Kit) error {
+	auth := kit.Auth().(Auth)
+	var user User
+	if err := db.Get().First(&user, auth.UserID).Error; err != nil {
+		return err
+	}
+	hasPassword := len(user.PasswordHash) > 0
+
+	var values AccountDeleteFormValues
+	errors, ok := v.Request(kit.Request, &values, accountDeleteSchema)
+	if !ok {
+		return kit.Render(AccountDeleteForm(hasPassword, errors))
+	}
+	if !hasPassword {
+		if !strings.EqualFold(strings.TrimSpace(values.Confirm), user.Email) {
+			errors.Add("confirm", "type your email to confirm")
+			return kit.Render(AccountDeleteForm(hasPassword, errors))
+		}
+	} else {
+		lockedUntil, locked, err := loginLockedUntil(accountThrottleKey(user.Email), ipThrottleKey(clientIP(kit.Request)))
+		if err != nil {
+			return err
+		}
+		if locked {
+			errors.Add("confirm", lockoutMessage(lockedUntil))
+			return kit.Render(AccountDeleteForm(hasPassword, errors))
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(values.Confirm))
+		if err != nil {
+			if err := recordLoginFailure(kit, user.Email, user); err != nil {
+				return err
+			}
+			errors.Add("confirm", "invalid password")
+			return kit.Render(AccountDeleteForm(hasPassword, errors))
+		}
+	}
+
+	if err := deleteAccount(user); err != nil {
+		return err
+	}
+	event.Emit(AccountDeletedEvent, user)
+
+	sess := kit.GetSession(userSessionName)
+	sess.Values = map[any]any{}
+	if err := sess.Save(kit.Request, kit.Response); err != nil {
+		return err
+	}
+	if err := kit.FlashInfo("Your account has been deleted."); err != nil {
+		return err
+	}
+	return kit.Redirect(http.StatusSeeOther, "/")
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestRegisterUserDataHook(t *testing.T) {
+	saved := userDataHooks
+	t.Cleanup(func() { userDataHooks = saved })
+	userDataHooks = nil
+
+	export := func(value string) func(*gorm.DB, uint) (any, error) {
+		return func(*gorm.DB, uint) (any, error) { return value, nil }
+	}
+	RegisterUserDataHook(UserDataHook{Name: "orders", Export: export("first")})
+	RegisterUserDataHook(UserDataHook{Name: "reservations", Export: export("reservations")})
+	// Registering again, like a plugin initialized twice, replaces the hook.
+	RegisterUserDataHook(UserDataHook{Name: "orders", Export: export("second")})
+
+	if len(userDataHooks) != 2 {
+		t.Fatalf("got %d hooks, want 2", len(userDataHooks))
+	}
+	if got, _ := userDataHooks[0].Export(nil, 1); userDataHooks[0].Name != "orders" || got != "second" {
+		t.Errorf("first hook is %s exporting %v, want orders exporting second", userDataHooks[0].Name, got)
+	}
+}
@@ -3,43 +3,42 @@ package delivery
 import (
 	"fmt"
 	"gothstack/app/db"
-	"strconv"
-
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
-	"github.com/go-chi/chi/v5"
+	"gothstack/kit"
+	"gothstack/kit/money"
+	v "gothstack/kit/validate"
+	"net/http"
 )
 
-// Validation schema for meal option
+// Validation schema for meal option. Price and quantity are pointers, so
+// Required only rejects missing values and 0 is allowed.
 var mealOptionSchema = v.Schema{
-	"Name":             v.Rules(v.Required, v.Max(255)),
-	"Description":      v.Rules(v.Required, v.Max(1000)),
-	"Price":            v.Rules(v.Required, v.Min(0)),
-	"NutritionalInfo":  v.Rules(v.Required),
-	"MaxDailyQuantity": v.Rules(v.Required, v.Min(0)),
-	"MealPlanID":       v.Rules(v.Required),
+	"name":               v.Rules(v.Required, v.Max(255)),
+	"description":        v.Rules(v.Required, v.Max(1000)),
+	"price":              v.Rules(v.Required, v.Min(0)),
+	"nutritional_info":   v.Rules(v.Required),
+	"max_daily_quantity": v.Rules(v.Required, v.Min(0)),
+	"meal_plan_id":       v.Rules(v.Required),
 }
 
 // MealOptionFormValues struct for form handling
 type MealOptionFormValues struct {
-	MealPlanID          string   `form:"meal_plan_id"`
-	Name                string   `form:"name"`
-	Description         string   `form:"description"`
-	Price               string   `form:"price"`
-	NutritionalInfo     string   `form:"nutritional_info"`
-	MaxDailyQuantity    string   `form:"max_daily_quantity"`
-	DietaryRestrictions []string `form:"dietary_restrictions"`
+	MealPlanID          uint          `form:"meal_plan_id" param:"id"`
+	Name                string        `form:"name"`
+	Description         string        `form:"description"`
+	Price               *money.Amount `form:"price"`
+	NutritionalInfo     string        `form:"nutritional_info"`
+	MaxDailyQuantity    *int          `form:"max_daily_quantity"`
+	DietaryRestrictions []uint        `form:"dietary_restrictions"`
 	Success             string
 }
 
 // GET handler to display the meal option form
 func handleMealOptionForm(kit *kit.Kit) error {
-	// Get mealPlanID from URL parameters or query string
-	idStr := chi.URLParam(kit.Request, "id")
-
-	// Prepare empty form values
-	values := MealOptionFormValues{
-		MealPlanID: idStr,
+	// Prefill the meal plan ID from the URL parameters or query string,
+	// which must be numbers.
+	var values MealOptionFormValues
+	if errs := v.Bind(kit.Request, &values); errs.Any() {
+		return kit.Error(http.StatusBadRequest, "The request contains invalid values", nil)
 	}
 
 	// Fetch available dietary restrictions for the form
@@ -64,51 +63,15 @@ func handlePostMealOption(kit *kit.Kit) error {
 		return kit.Render(MealOptionForm(values, restrictions, errors))
 	}
 
-	// Convert form values to appropriate types
-	mealPlanID := values.MealPlanID
-	if mealPlanID == "" {
-		errors.Add("MealPlanID", "Invalid meal plan ID")
-		restrictions, _ := GetAllDietaryRestrictions()
-		return kit.Render(MealOptionForm(values, restrictions, errors))
-	}
-
-	price, err := strconv.ParseFloat(values.Price, 64)
-	if err != nil {
-		errors.Add("Price", "Invalid price value")
-		restrictions, _ := GetAllDietaryRestrictions()
-		return kit.Render(MealOptionForm(values, restrictions, errors))
-	}
-
-	maxDaily, err := strconv.Atoi(values.MaxDailyQuantity)
-	if err != nil {
-		errors.Add("MaxDailyQuantity", "Invalid quantity value")
-		restrictions, _ := GetAllDietaryRestrictions()
-		return kit.Render(MealOptionForm(values, restrictions, errors))
-	}
-
-	// Convert dietary restriction IDs
-	var dietaryRestrictionIDs []uint
-	for _, id := range values.DietaryRestrictions {
-		idVal, err := strconv.ParseUint(id, 10, 64)
-		if err != nil {
-			continue // Skip invalid IDs
-		}
-		dietaryRestrictionIDs = append(dietaryRestrictionIDs, uint(idVal))
-	}
-
 	// Create the meal option
-	mealID, err := strconv.ParseUint(mealPlanID, 10, 64)
-	if err != nil {
-		return fmt.Errorf("invalid meal plan ID: %w", err)
-	}
 	mealOption, err := CreateMealOption(
-		uint(mealID),
+		values.MealPlanID,
 		values.Name,
 		values.Description,
-		price,
+		*values.Price,
 		values.NutritionalInfo,
-		maxDaily,
-		dietaryRestrictionIDs,
+		*values.MaxDailyQuantity,
+		values.DietaryRestrictions,
 	)
 
 	if err != nil {
@@ -125,9 +88,9 @@ func handlePostMealOption(kit *kit.Kit) error {
 }
 func handleShowMeals(kit *kit.Kit) error {
 	// Get mealPlanID from URL parameters
-	mealPlanID := chi.URLParam(kit.Request, "id")
-	if mealPlanID == "" {
-		return fmt.Errorf("missing meal plan ID")
+	mealPlanID, err := kit.URLParamID("id")
+	if err != nil {
+		return err
 	}
 
 	// Fetch all meal options for the given meal plan ID
@@ -136,5 +99,5 @@ func handleShowMeals(kit *kit.Kit) error {
 		return fmt.Errorf("error fetching meal options: %w", err)
 	}
 
-	return kit.Render(MealOptionList(options, mealPlanID))
+	return kit.Respond(http.StatusOK, options, MealOptionList(options, fmt.Sprint(mealPlanID)))
 }
@@ -1,6 +1,6 @@
 package delivery
 
 /* import (
+	v "gothstack/kit/validate"
 	"gothstack/kit"
-	v "github.com/anthdm/superkit/validate"
 ) */
@@ -0,0 +1,39 @@
+package delivery
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"gothstack/app/views/emails"
+)
+
+//go:embed mail/*.txt
+var mailFS embed.FS
+
+// mailer sends the emails of the plugin, set by Init.
+var mailer *emails.Mailer
+
+type orderMailData struct {
+	Order Order
+	Link  string
+}
+
+func onOrderCreated(ctx context.Context, event any) {
+	order, ok := event.(Order)
+	if !ok {
+		return
+	}
+	data := orderMailData{Order: order, Link: mailer.Link("/meal-plans", "", "")}
+	mailer.Send(ctx, order.User.Email, fmt.Sprintf("Your order #%d is confirmed", order.ID),
+		OrderConfirmationMail(data.Order, data.Link), "order_confirmation.txt", data)
+}
+
+func onOrderCanceled(ctx context.Context, event any) {
+	order, ok := event.(Order)
+	if !ok {
+		return
+	}
+	data := orderMailData{Order: order, Link: mailer.Link("/meal-plans", "", "")}
+	mailer.Send(ctx, order.User.Email, fmt.Sprintf("Your order #%d was canceled", order.ID),
+		OrderCanceledMail(data.Order, data.Link), "order_canceled.txt", data)
+}
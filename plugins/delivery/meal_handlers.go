@@ -2,9 +2,8 @@ package delivery
 
 import (
 	"fmt"
-
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
 )
 
 // Validation schema for meal center
@@ -40,7 +39,6 @@ func handlePostMealCenter(kit *kit.Kit) error {
 	errors, ok := v.Request(kit.Request, &values, mealCenterSchema)
 
 	if !ok {
-		fmt.Println(errors)
 		return kit.Render(MealCenterForm(values, errors))
 	}
 	long, lat, err := fetchLongLat(values.Address)
@@ -58,7 +56,7 @@ func handlePostMealCenter(kit *kit.Kit) error {
 	)
 
 	if err != nil {
-		fmt.Println(err)
+		kit.Logger().Error("create meal center", "err", err)
 		// Add general error
 		errors.Add("general", "Failed to create meal center")
 		return kit.Render(MealCenterForm(values, errors))
@@ -1,34 +1,37 @@
 package delivery
 
 import (
-	"errors"
 	"fmt"
 	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"net/http"
 	"strconv"
 	"time"
-
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
-	"github.com/go-chi/chi/v5"
-	"gorm.io/gorm"
 )
 
 // Validation schema for meal plan
 var mealPlanSchema = v.Schema{
-	"Name":        v.Rules(v.Required, v.Max(255)),
-	"Description": v.Rules(v.Max(1000)),
-	"MealDate":    v.Rules(v.Required),
+	"name":        v.Rules(v.Required, v.Max(255)),
+	"description": v.Rules(v.Max(1000)),
+	"meal_date":   v.Rules(v.Required),
 }
 
 // MealPlanFormValues struct for form handling
 type MealPlanFormValues struct {
-	MealCenterID uint   `form:"meal_center_id"`
-	Name         string `form:"name"`
-	Description  string `form:"description"`
-	MealDate     string `form:"meal_date"` // Format: YYYY-MM-DD
+	MealCenterID uint      `form:"meal_center_id"`
+	Name         string    `form:"name"`
+	Description  string    `form:"description"`
+	MealDate     time.Time `form:"meal_date" layout:"2006-01-02"`
 	Success      string
 }
 
+// MealPlanData is the JSON representation of a meal plan with its options
+type MealPlanData struct {
+	Plan        DaysMeals
+	MealOptions []MealOption
+}
+
 // GET handler to display the meal plan form
 func handleMealPlanForm(kit *kit.Kit) error {
 
@@ -43,7 +46,7 @@ func handleMealPlanForm(kit *kit.Kit) error {
 	// Prepare form values
 	values := MealPlanFormValues{
 		MealCenterID: centerID,
-		MealDate:     time.Now().Format("2006-01-02"),
+		MealDate:     time.Now(),
 	}
 
 	// Render the form
@@ -52,11 +55,9 @@ func handleMealPlanForm(kit *kit.Kit) error {
 
 // POST handler to process the meal plan form submission
 func handlePostMealPlan(kit *kit.Kit) error {
-	fmt.Println("handlePostMealPlan")
 	// Parse and validate form values
 	var values MealPlanFormValues
 	errors, ok := v.Request(kit.Request, &values, mealPlanSchema)
-	fmt.Println(values)
 	// Fetch all meal centers for dropdown (needed for re-rendering form with errors)
 	var centers []MealCenter
 	if err := db.Get().Find(&centers).Error; err != nil {
@@ -64,14 +65,6 @@ func handlePostMealPlan(kit *kit.Kit) error {
 	}
 
 	if !ok {
-		fmt.Println(ok, errors)
-		return kit.Render(MealPlanForm(values, errors, centers))
-	}
-
-	// Parse dates
-	startDate, err := time.Parse("2006-01-02", values.MealDate)
-	if err != nil {
-		errors.Add("StartDate", "Invalid date format")
 		return kit.Render(MealPlanForm(values, errors, centers))
 	}
 
@@ -80,11 +73,11 @@ func handlePostMealPlan(kit *kit.Kit) error {
 		values.MealCenterID,
 		values.Name,
 		values.Description,
-		startDate,
+		values.MealDate,
 	)
 
 	if err != nil {
-		fmt.Println(err)
+		kit.Logger().Error("create meal plan", "err", err)
 		// Add general error
 		errors.Add("general", "Failed to create meal plan: "+err.Error())
 		return kit.Render(MealPlanForm(values, errors, centers))
@@ -98,33 +91,22 @@ func handlePostMealPlan(kit *kit.Kit) error {
 // Function to get a meal plan by ID
 func handleGetMealPlan(kit *kit.Kit) error {
 	// Get ID from URL parameters
-	idStr := chi.URLParam(kit.Request, "id")
-
-	// Convert string ID to int
-	id, err := strconv.Atoi(idStr)
+	id, err := kit.URLParamID("id")
 	if err != nil {
-		return fmt.Errorf("invalid ID format: %w", err)
+		return err
 	}
 
-	fmt.Println("fetching meal plans")
 	var plan DaysMeals
 	// Make sure to use Preload properly
 	err = db.Get().Preload("MealCenter").First(&plan, id).Error
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			fmt.Println(err)
-			return err
-		}
-		fmt.Println(err)
 		return err
 	}
 	var mealOptions []MealOption
 	if err := db.Get().Where("days_meals_id = ?", id).Find(&mealOptions).Error; err != nil {
-		fmt.Println(err)
 		return err
 	}
-	fmt.Println(plan)
-	return kit.Render(ShowAllMealsInDay(mealOptions, plan))
+	return kit.Respond(http.StatusOK, MealPlanData{Plan: plan, MealOptions: mealOptions}, ShowAllMealsInDay(mealOptions, plan))
 }
 
 // Function to list all meal plans
@@ -153,12 +135,6 @@ func handleListMealPlans(kit *kit.Kit) error {
 		return err
 	}
 
-	// Check if this is an HTMX request
-	/* 	if kit.Request.Header.Get("HX-Request") == "true" {
-		// Return only the table component
-		return kit.Render(MealPlanTable(plans))
-	} */
-
-	// Return the full page
-	return kit.Render(MealPlanList(plans, centers))
+	// The center filter only swaps the table
+	return kit.RespondFragment(http.StatusOK, plans, MealPlanList(plans, centers), MealPlanTable(plans))
 }
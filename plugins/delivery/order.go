@@ -4,8 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/event"
+	"gothstack/kit/metrics"
+	"gothstack/kit/money"
 	"gothstack/plugins/auth"
+	"log/slog"
 	"math"
+	"net/http"
 	"time"
 
 	"gorm.io/gorm"
@@ -21,6 +27,11 @@ const (
 	OrderStatusCanceled  = "canceled"
 )
 
+var (
+	ordersPlaced   = metrics.NewCounter("delivery_orders_placed_total", "Orders placed.")
+	ordersCanceled = metrics.NewCounter("delivery_orders_canceled_total", "Orders canceled by their customer.")
+)
+
 // Order represents a meal order placed by a user
 type Order struct {
 	gorm.Model
@@ -31,7 +42,7 @@ type Order struct {
 	Status        string      `gorm:"default:pending"`
 	DeliveryDate  time.Time
 	Note          string
-	TotalPrice    float64
+	TotalPrice    money.Amount
 	OrderItems    []OrderItem   `gorm:"foreignKey:OrderID"`
 	Delivery      *DeliveryInfo `gorm:"foreignKey:OrderID"`
 }
@@ -43,7 +54,7 @@ type OrderItem struct {
 	MealOptionID uint
 	MealOption   MealOption `gorm:"foreignKey:MealOptionID"`
 	Quantity     int
-	Price        float64 // Price at time of order
+	Price        money.Amount // Price at time of order
 }
 
 // DeliveryInfo stores information about the delivery of an order
@@ -72,27 +83,27 @@ func PurchaseMealOption(userID, mealOptionID uint) (*Order, error) {
 		var mealOption MealOption
 		if err := tx.First(&mealOption, mealOptionID).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("meal option not found")
+				return kit.NotFoundError("Meal option not found")
 			}
 			return err
 		}
 
 		// 2. Check if the meal option is available
 		if !mealOption.IsAvailable {
-			return errors.New("meal option is not available")
+			return kit.NewError(http.StatusConflict, "This meal is not available", nil)
 		}
 
 		// 3. Check if the requested quantity is available
 		remainingQuantity := mealOption.MaxDailyQuantity - mealOption.CurrentDailyQuantity
 		if 1 > remainingQuantity {
-			return errors.New("insufficient quantity available")
+			return kit.NewError(http.StatusConflict, "This meal is sold out", nil)
 		}
 
 		// 4. Get the user profile
 		var userProfile UserProfile
 		if err := tx.Where("user_id = ?", userID).First(&userProfile).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("user profile not found, please complete your profile before ordering")
+				return kit.NewError(http.StatusConflict, "Please complete your profile before ordering", nil)
 			}
 			return err
 		}
@@ -108,7 +119,7 @@ func PurchaseMealOption(userID, mealOptionID uint) (*Order, error) {
 
 		// Make sure delivery date is not in the past
 		if deliveryDate.Before(time.Now()) {
-			return errors.New("delivery date cannot be in the past")
+			return kit.NewError(http.StatusConflict, "This meal can no longer be ordered", nil)
 		}
 
 		// 6. Create the order
@@ -157,21 +168,22 @@ func PurchaseMealOption(userID, mealOptionID uint) (*Order, error) {
 			return err
 		}
 
-		// 10. Emit order created event (placeholder for your event system)
-		// EmitEvent(OrderCreatedEvent, order.ID)
-
 		return nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
+	ordersPlaced.Inc()
 
 	// Load the order with relationships
-	if err := db.Get().Preload("OrderItems.MealOption").Preload("UserProfile").Preload("Delivery").First(&order, order.ID).Error; err != nil {
+	if err := db.Get().Preload("OrderItems.MealOption").Preload("UserProfile").Preload("User").Preload("Delivery").First(&order, order.ID).Error; err != nil {
 		return nil, err
 	}
 
+	// Emit the event once the order is committed
+	event.Emit(OrderCreatedEvent, *order)
+
 	return order, nil
 }
 
@@ -215,7 +227,7 @@ func GetOrder(orderID uint) (*Order, error) {
 	result := db.Get().Preload("OrderItems.MealOption").Preload("UserProfile").Preload("Delivery").First(&order, orderID)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
+			return nil, kit.NotFoundError("Order not found")
 		}
 		return nil, result.Error
 	}
@@ -224,19 +236,19 @@ func GetOrder(orderID uint) (*Order, error) {
 
 // CancelOrder cancels an existing order if it's in a cancelable state
 func CancelOrder(orderID, userID uint) error {
-	return db.Get().Transaction(func(tx *gorm.DB) error {
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
 		// Get the order
 		var order Order
 		if err := tx.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("order not found")
+				return kit.NotFoundError("Order not found")
 			}
 			return err
 		}
 
 		// Check if order can be canceled
 		if order.Status != OrderStatusPending && order.Status != OrderStatusConfirmed {
-			return errors.New("order cannot be canceled in its current state")
+			return kit.NewError(http.StatusConflict, "The order can no longer be canceled", nil)
 		}
 
 		// Update order status
@@ -271,11 +283,20 @@ func CancelOrder(orderID, userID uint) error {
 			}
 		}
 
-		// Emit order canceled event (placeholder for your event system)
-		// EmitEvent(OrderCanceledEvent, order.ID)
-
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	ordersCanceled.Inc()
+
+	var order Order
+	if err := db.Get().Preload("OrderItems.MealOption").Preload("User").Preload("Delivery").First(&order, orderID).Error; err != nil {
+		return err
+	}
+	event.Emit(OrderCanceledEvent, order)
+
+	return nil
 }
 
 // OptimizeDeliveryRoute creates an optimized delivery route for a driver
@@ -284,8 +305,7 @@ func OptimizeDeliveryRoute(deliveries []DeliveryInfo, startLat, startLng float64
 	if len(deliveries) == 0 {
 		return deliveries, nil
 	}
-	fmt.Println("Optimizing route for", len(deliveries), "deliveries")
-	fmt.Println("*******Starting coordinates:", startLat, startLng)
+	slog.Debug("optimizing delivery route", "deliveries", len(deliveries), "start_latitude", startLat, "start_longitude", startLng)
 	// Create a copy of the deliveries to sort
 	optimizedRoute := make([]DeliveryInfo, len(deliveries))
 	copy(optimizedRoute, deliveries)
@@ -0,0 +1,85 @@
+package delivery
+
+import (
+	"context"
+	"embed"
+	"gothstack/app/db"
+	"gothstack/app/plugin"
+	"gothstack/app/views/emails"
+	"gothstack/plugins/auth"
+	"io/fs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Plugin is the meal delivery plugin: meal plans, orders and deliveries.
+type Plugin struct {
+	plugin.Base
+}
+
+func (Plugin) Name() string { return "delivery" }
+
+func (Plugin) Requires() []string { return []string{"auth"} }
+
+func (Plugin) Init(app plugin.App) error {
+	mailer = emails.NewMailer(app.Config, mailFS, "mail/*.txt")
+	auth.RegisterUserDataHook(auth.UserDataHook{
+		Name:   "delivery",
+		Export: ExportUserData,
+		Erase:  EraseUserData,
+	})
+	return nil
+}
+
+func (Plugin) Routes(router chi.Router, app plugin.App) {
+	InitRoutes(router, app.Auth)
+}
+
+// Events sends the emails of the plugin.
+func (Plugin) Events() []plugin.Subscription {
+	return []plugin.Subscription{
+		{Topic: OrderCreatedEvent, Handler: onOrderCreated},
+		{Topic: OrderCanceledEvent, Handler: onOrderCanceled},
+	}
+}
+
+func (Plugin) Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
+
+func (Plugin) Nav() []plugin.NavItem {
+	return []plugin.NavItem{
+		{Label: "edit", URL: "/create-profile", Section: plugin.NavPublic},
+		{Label: "meals", URL: "/meal-plans", Section: plugin.NavMain},
+		{Label: "c-center", URL: "/create-meal-center", Section: plugin.NavMain, Permission: auth.PermissionManageMeals},
+		{Label: "deliveries", URL: "/deliveries", Section: plugin.NavMain, Permission: auth.PermissionViewDeliveries},
+	}
+}
+
+// dietaryRestrictions are the restrictions meal options and profiles can
+// be tagged with.
+var dietaryRestrictions = []DietaryRestriction{
+	{Name: "Vegetarian", Description: "No meat or fish"},
+	{Name: "Vegan", Description: "No animal products"},
+	{Name: "Gluten-free", Description: "No wheat, barley or rye"},
+	{Name: "Dairy-free", Description: "No milk products"},
+	{Name: "Nut-free", Description: "No peanuts or tree nuts"},
+	{Name: "Halal", Description: "Prepared according to Islamic law"},
+	{Name: "Kosher", Description: "Prepared according to Jewish law"},
+}
+
+func (Plugin) Seed(ctx context.Context) error {
+	for _, restriction := range dietaryRestrictions {
+		err := db.Get().WithContext(ctx).
+			Where(DietaryRestriction{Name: restriction.Name}).
+			FirstOrCreate(&restriction).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,12 +2,11 @@ package delivery
 
 import (
 	"errors"
-	"fmt"
 	"gothstack/app/db"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
 	"gothstack/plugins/auth"
 
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
 	"gorm.io/gorm"
 )
 
@@ -76,9 +75,7 @@ func handlePostUserProfile(kit *kit.Kit) error {
 	// Parse and validate form values
 	var values UserProfileFormValues
 	errors, ok := v.Request(kit.Request, &values, profileSchema)
-	fmt.Println(values.DietaryNotes)
 	if !ok {
-		fmt.Println(errors)
 		return kit.Render(UserProfileForm(values, errors))
 	}
 	_, err := CreateUserProfile(
@@ -90,7 +87,7 @@ func handlePostUserProfile(kit *kit.Kit) error {
 		[]uint{},
 	)
 	if err != nil {
-		fmt.Println(err)
+		kit.Logger().Error("create user profile", "user", userID, "err", err)
 		return kit.Render(UserProfileForm(values, errors))
 	}
 
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"gothstack/app/db"
+	"gothstack/kit"
+	"gothstack/kit/money"
 	"gothstack/plugins/auth"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -57,7 +59,7 @@ type MealOption struct {
 	DaysMealsID          uint
 	Name                 string
 	Description          string
-	Price                float64
+	Price                money.Amount
 	Image                string
 	NutritionalInfo      string
 	IsAvailable          bool
@@ -107,7 +109,7 @@ func CreateMealPlan(mealCenterID uint, name, description string, mealDate time.T
 	// Check if meal center exists
 	var center MealCenter
 	if err := db.Get().First(&center, mealCenterID).Error; err != nil {
-		return DaysMeals{}, errors.New("meal center not found")
+		return DaysMeals{}, kit.NotFoundError("Meal center not found")
 	}
 
 	plan := DaysMeals{
@@ -138,7 +140,7 @@ func CreateMealOption(
 	DaysMealsID uint,
 	name,
 	description string,
-	price float64,
+	price money.Amount,
 	nutritionalInfo string,
 	maxDaily int,
 	dietaryRestrictionIDs []uint,
@@ -146,7 +148,7 @@ func CreateMealOption(
 	// Check if meal plan exists
 	var plan DaysMeals
 	if err := db.Get().First(&plan, DaysMealsID).Error; err != nil {
-		return MealOption{}, errors.New("meal plan not found")
+		return MealOption{}, kit.NotFoundError("Meal plan not found")
 	}
 
 	// Create meal option
@@ -176,45 +178,36 @@ func CreateMealOption(
 }
 
 func fetchLongLat(address string) (float64, float64, error) {
-	log.Printf("Starting fetchLongLat for address: %s", address)
 	if strings.TrimSpace(address) == "" {
-		log.Printf("Empty address provided")
 		return 0, 0, errors.New("empty address provided")
 	}
 
 	// URL encode the address
 	encodedAddress := url.QueryEscape(address)
-	log.Printf("Encoded address: %s", encodedAddress)
 	requestURL := fmt.Sprintf(
 		"https://nominatim.openstreetmap.org/search?q=%s&format=json&limit=1",
 		encodedAddress,
 	)
-	log.Printf("Constructed request URL: %s", requestURL)
 
 	// Add a user agent (required by Nominatim's terms of use)
 	client := &http.Client{Timeout: 10 * time.Second}
 	req, err := http.NewRequest("GET", requestURL, nil)
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
 		return 0, 0, fmt.Errorf("error creating request: %w", err)
 	}
 
 	// Add required headers
 	req.Header.Set("User-Agent", "YourAppName/1.0")
-	log.Printf("Added User-Agent header")
 
 	// Make the request
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Error making request to OpenStreetMap API: %v", err)
 		return 0, 0, fmt.Errorf("error making request to OpenStreetMap API: %w", err)
 	}
 	defer resp.Body.Close()
-	log.Printf("Received response with status code: %d", resp.StatusCode)
 
 	// Check status
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("OpenStreetMap API returned non-200 status: %d", resp.StatusCode)
 		return 0, 0, fmt.Errorf("OpenStreetMap API returned non-200 status: %d", resp.StatusCode)
 	}
 
@@ -224,32 +217,25 @@ func fetchLongLat(address string) (float64, float64, error) {
 		Lon string `json:"lon"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
-		log.Printf("Error parsing OpenStreetMap response: %v", err)
 		return 0, 0, fmt.Errorf("error parsing OpenStreetMap response: %w", err)
 	}
-	log.Printf("Parsed response: %+v", results)
 
 	if len(results) == 0 {
-		log.Printf("No coordinates found for the provided address")
 		return 0, 0, errors.New("no coordinates found for the provided address")
 	}
 
 	// Convert string coordinates to float64
 	lat, err := strconv.ParseFloat(results[0].Lat, 64)
 	if err != nil {
-		log.Printf("Error parsing latitude: %v", err)
-		return 0, 0, fmt.Errorf("error parsing latitude: %w", err)
+		return 0, 0, fmt.Errorf("error parsing latitude: %v", err)
 	}
-	log.Printf("Parsed latitude: %f", lat)
 
 	lng, err := strconv.ParseFloat(results[0].Lon, 64)
 	if err != nil {
-		log.Printf("Error parsing longitude: %v", err)
-		return 0, 0, fmt.Errorf("error parsing longitude: %w", err)
+		return 0, 0, fmt.Errorf("error parsing longitude: %v", err)
 	}
-	log.Printf("Parsed longitude: %f", lng)
 
-	log.Printf("Returning coordinates: longitude=%f, latitude=%f", lng, lat)
+	slog.Debug("geocoded address", "longitude", lng, "latitude", lat)
 	return lng, lat, nil
 }
 
@@ -267,7 +253,6 @@ func CreateUserProfile(
 	if err != nil {
 		return UserProfile{}, err
 	}
-	log.Printf("updating prof longitude=%f, latitude=%f", long, lat)
 	// Create new profile or update
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -0,0 +1,160 @@
+package delivery
+
+import (
+	"gothstack/kit/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// profileExport is the delivery profile of a user in the personal data export.
+type profileExport struct {
+	Address             string    `json:"address"`
+	Latitude            float64   `json:"latitude"`
+	Longitude           float64   `json:"longitude"`
+	PhoneNumber         string    `json:"phone_number"`
+	DeliveryNotes       string    `json:"delivery_notes"`
+	DietaryNotes        string    `json:"dietary_notes"`
+	DietaryRestrictions []string  `json:"dietary_restrictions"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type orderExport struct {
+	ID           uint              `json:"id"`
+	Status       string            `json:"status"`
+	DeliveryDate time.Time         `json:"delivery_date"`
+	Note         string            `json:"note"`
+	TotalPrice   money.Amount      `json:"total_price"`
+	Items        []orderItemExport `json:"items"`
+	Delivery     *deliveryExport   `json:"delivery,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+type orderItemExport struct {
+	Meal     string       `json:"meal"`
+	Quantity int          `json:"quantity"`
+	Price    money.Amount `json:"price"`
+}
+
+type deliveryExport struct {
+	Status        string     `json:"status"`
+	Address       string     `json:"address"`
+	Latitude      float64    `json:"latitude"`
+	Longitude     float64    `json:"longitude"`
+	Notes         string     `json:"notes"`
+	ScheduledTime time.Time  `json:"scheduled_time"`
+	ActualTime    *time.Time `json:"actual_time,omitempty"`
+}
+
+type userDataExport struct {
+	Profile *profileExport `json:"profile"`
+	Orders  []orderExport  `json:"orders"`
+}
+
+// ExportUserData returns the delivery profile and orders of the user for
+// the personal data export of the auth plugin.
+func ExportUserData(tx *gorm.DB, userID uint) (any, error) {
+	data := userDataExport{Orders: []orderExport{}}
+
+	var profiles []UserProfile
+	if err := tx.Preload("DietaryRestrictions").Where("user_id = ?", userID).Limit(1).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	if len(profiles) > 0 {
+		profile := profiles[0]
+		data.Profile = &profileExport{
+			Address:             profile.Address,
+			Latitude:            profile.Latitude,
+			Longitude:           profile.Longitude,
+			PhoneNumber:         profile.PhoneNumber,
+			DeliveryNotes:       profile.DeliveryNotes,
+			DietaryNotes:        profile.DietaryNotes,
+			DietaryRestrictions: []string{},
+			CreatedAt:           profile.CreatedAt,
+		}
+		for _, restriction := range profile.DietaryRestrictions {
+			data.Profile.DietaryRestrictions = append(data.Profile.DietaryRestrictions, restriction.Name)
+		}
+	}
+
+	var orders []Order
+	err := tx.Preload("OrderItems.MealOption").Preload("Delivery").
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		export := orderExport{
+			ID:           order.ID,
+			Status:       order.Status,
+			DeliveryDate: order.DeliveryDate,
+			Note:         order.Note,
+			TotalPrice:   order.TotalPrice,
+			Items:        []orderItemExport{},
+			CreatedAt:    order.CreatedAt,
+		}
+		for _, item := range order.OrderItems {
+			export.Items = append(export.Items, orderItemExport{
+				Meal:     item.MealOption.Name,
+				Quantity: item.Quantity,
+				Price:    item.Price,
+			})
+		}
+		if delivery := order.Delivery; delivery != nil {
+			export.Delivery = &deliveryExport{
+				Status:        delivery.DeliveryStatus,
+				Address:       delivery.DeliveryAddress,
+				Latitude:      delivery.Latitude,
+				Longitude:     delivery.Longitude,
+				Notes:         delivery.DeliveryNotes,
+				ScheduledTime: delivery.ScheduledTime,
+				ActualTime:    delivery.ActualTime,
+			}
+		}
+		data.Orders = append(data.Orders, export)
+	}
+	return data, nil
+}
+
+// EraseUserData removes the address, coordinates, phone number and notes
+// of the user from their profile, orders and deliveries. Orders and their
+// items are kept so kitchen statistics stay correct.
+func EraseUserData(tx *gorm.DB, userID uint) error {
+	var profileIDs []uint
+	if err := tx.Unscoped().Model(&UserProfile{}).Where("user_id = ?", userID).Pluck("id", &profileIDs).Error; err != nil {
+		return err
+	}
+	if len(profileIDs) > 0 {
+		err := tx.Exec("DELETE FROM user_dietary_restrictions WHERE user_profile_id IN ?", profileIDs).Error
+		if err != nil {
+			return err
+		}
+	}
+	err := tx.Unscoped().Model(&UserProfile{}).Where("user_id = ?", userID).Updates(map[string]any{
+		"address":        "",
+		"latitude":       0,
+		"longitude":      0,
+		"phone_number":   "",
+		"delivery_notes": "",
+		"dietary_notes":  "",
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Unscoped().Model(&DeliveryInfo{}).
+		Where("order_id IN (?)", tx.Unscoped().Model(&Order{}).Select("id").Where("user_id = ?", userID)).
+		Updates(map[string]any{
+			"delivery_address": "",
+			"latitude":         0,
+			"longitude":        0,
+			"delivery_notes":   "",
+			"custom_address":   false,
+		}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&Order{}).Where("user_id = ?", userID).Update("note", "").Error
+}
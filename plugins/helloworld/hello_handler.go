@@ -2,9 +2,8 @@ package helloworld
 
 import (
 	"fmt"
-
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
 )
 
 var helloworldSchema = v.Schema{
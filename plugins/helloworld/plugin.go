@@ -0,0 +1,58 @@
+package helloworld
+
+import (
+	"context"
+	"embed"
+	"gothstack/app/plugin"
+	"io/fs"
+	"log/slog"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Plugin is the example plugin. It shows every part a plugin can have.
+type Plugin struct {
+	plugin.Base
+}
+
+func (Plugin) Name() string { return "helloworld" }
+
+func (Plugin) Routes(router chi.Router, app plugin.App) {
+	InitRoutes(router, app.Auth)
+}
+
+func (Plugin) Events() []plugin.Subscription {
+	return []plugin.Subscription{
+		{Topic: HelloworldNewEvent, Handler: onNewMessage},
+	}
+}
+
+func (Plugin) Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
+
+func (Plugin) Nav() []plugin.NavItem {
+	return []plugin.NavItem{
+		{Label: "Hello", URL: "/hello", Section: plugin.NavMain},
+		{Label: "Read", URL: "/hello/read", Section: plugin.NavMain},
+	}
+}
+
+func (Plugin) Seed(ctx context.Context) error {
+	messages, err := listMessages()
+	if err != nil || len(messages) > 0 {
+		return err
+	}
+	_, err = createMessage("Hello, world!")
+	return err
+}
+
+func onNewMessage(ctx context.Context, event any) {
+	if message, ok := event.(HelloworldMessage); ok {
+		slog.Info("new hello message", "message", message.ID)
+	}
+}
@@ -1,7 +1,8 @@
 package helloworld
 
 import (
-	"github.com/anthdm/superkit/kit"
+	"gothstack/kit"
+
 	"github.com/go-chi/chi/v5"
 )
 
@@ -2,6 +2,7 @@ package helloworld
 
 import (
 	"gothstack/app/db"
+	"gothstack/kit/event"
 	"time"
 
 	"gorm.io/gorm"
@@ -26,7 +27,11 @@ func createMessage(message string) (HelloworldMessage, error) {
 		Message: message,
 	}
 	result := db.Get().Create(&hello)
-	return hello, result.Error
+	if result.Error != nil {
+		return hello, result.Error
+	}
+	event.Emit(HelloworldNewEvent, hello)
+	return hello, nil
 }
 func listMessages() ([]HelloworldMessage, error) {
 	var messages []HelloworldMessage
@@ -2,39 +2,38 @@ package reservation
 
 import (
 	"fmt"
-	"strconv"
+	"gothstack/kit"
+	v "gothstack/kit/validate"
+	"gothstack/plugins/auth"
+	"net/http"
 	"time"
-
-	"github.com/anthdm/superkit/kit"
-	v "github.com/anthdm/superkit/validate"
-	"github.com/go-chi/chi/v5"
 )
 
 // Form validation schemas
 var timeSlotSchema = v.Schema{
-	"title":     v.Rules(v.Min(1), v.Max(100)),
-	"startTime": v.Rules(v.Min(1)), // These will be validated further in handler
-	"endTime":   v.Rules(v.Min(1)),
-	"capacity":  v.Rules(v.Min(1)),
+	"title":     v.Rules(v.Required, v.Max(100)),
+	"startTime": v.Rules(v.Required, v.Future),
+	"endTime":   v.Rules(v.Required, v.After("startTime").Message("must be after the start time")),
+	"capacity":  v.Rules(v.Required, v.Min(1)),
 }
 
 var reservationSchema = v.Schema{
-	"timeSlotID": v.Rules(v.Min(1)),
+	"timeSlotID": v.Rules(v.Required.Message("select a time slot")),
 	"notes":      v.Rules(v.Max(500)),
 }
 
 // Form data structures
 type TimeSlotFormValues struct {
-	Title          string
-	StartTime      string
-	EndTime        string
-	Capacity       string
+	Title          string    `form:"title"`
+	StartTime      time.Time `form:"startTime"`
+	EndTime        time.Time `form:"endTime"`
+	Capacity       int       `form:"capacity"`
 	SuccessMessage string
 }
 
 type ReservationFormValues struct {
-	TimeSlotID     string
-	Notes          string
+	TimeSlotID     uint   `form:"timeSlotID"`
+	Notes          string `form:"notes"`
 	SuccessMessage string
 }
 
@@ -54,7 +53,8 @@ func HandleListTimeSlots(kit *kit.Kit) error {
 	if err != nil {
 		return err
 	}
-	return kit.Render(TimeSlotsList(TimeSlotPageData{TimeSlots: slots}))
+	data := TimeSlotPageData{TimeSlots: slots}
+	return kit.Respond(http.StatusOK, data, TimeSlotsList(data))
 }
 
 func HandleCreateTimeSlotForm(kit *kit.Kit) error {
@@ -68,40 +68,13 @@ func HandleCreateTimeSlot(kit *kit.Kit) error {
 		return kit.Render(CreateTimeSlotForm(values, errors))
 	}
 
-	// Parse time values
-	startTime, err := time.Parse("2006-01-02T15:04", values.StartTime)
-	if err != nil {
-		errors["startTime"] = []string{"Invalid start time format"}
-		return kit.Render(CreateTimeSlotForm(values, errors))
-	}
-
-	endTime, err := time.Parse("2006-01-02T15:04", values.EndTime)
+	slot, err := CreateTimeSlot(values.StartTime, values.EndTime, values.Title, values.Capacity)
 	if err != nil {
-		errors["endTime"] = []string{"Invalid end time format"}
-		return kit.Render(CreateTimeSlotForm(values, errors))
-	}
-
-	if startTime.After(endTime) {
-		errors["startTime"] = []string{"Start time must be before end time"}
+		kit.Logger().Error("create time slot", "err", err)
+		errors.Add("general", "Failed to create the time slot")
 		return kit.Render(CreateTimeSlotForm(values, errors))
 	}
-
-	if startTime.Before(time.Now()) {
-		errors["startTime"] = []string{"Start time cannot be in the past"}
-		return kit.Render(CreateTimeSlotForm(values, errors))
-	}
-
-	capacity, err := strconv.Atoi(values.Capacity)
-	if err != nil || capacity < 1 {
-		errors["capacity"] = []string{"Capacity must be a positive number"}
-		return kit.Render(CreateTimeSlotForm(values, errors))
-	}
-
-	slot, err := CreateTimeSlot(startTime, endTime, values.Title, capacity)
-	if err != nil {
-		return kit.Render(CreateTimeSlotForm(values, errors))
-	}
-	fmt.Println(slot)
+	kit.Logger().Info("time slot created", "slot", slot.ID)
 
 	values.SuccessMessage = fmt.Sprintf("New time slot created: %s", values.Title)
 	return kit.Render(CreateTimeSlotForm(values, errors))
@@ -112,7 +85,7 @@ func HandleReservationForm(kit *kit.Kit) error {
 	if err != nil {
 		return err
 	}
-	return kit.Render(CreateReservationForm(ReservationFormValues{}, slots))
+	return kit.Render(CreateReservationForm(ReservationFormValues{}, slots, v.Errors{}))
 }
 
 func HandleCreateReservation(kit *kit.Kit) error {
@@ -128,27 +101,21 @@ func HandleCreateReservation(kit *kit.Kit) error {
 	slots, _ := GetAvailableTimeSlots() // Get slots for re-rendering the form if needed
 
 	if !ok {
-		return kit.Render(CreateReservationForm(values, slots))
+		return kit.Render(CreateReservationForm(values, slots, errors))
 	}
 
-	timeSlotID, err := strconv.ParseUint(values.TimeSlotID, 10, 32)
-	if err != nil {
-		errors["timeSlotID"] = []string{"Invalid time slot ID"}
-		return kit.Render(CreateReservationForm(values, slots))
-	}
-
-	reservation, err := ReserveTimeSlot(uint(timeSlotID), userID, values.Notes)
+	reservation, err := ReserveTimeSlot(values.TimeSlotID, userID, values.Notes)
 	if err != nil {
 		errors["timeSlotID"] = []string{err.Error()}
-		return kit.Render(CreateReservationForm(values, slots))
+		return kit.Render(CreateReservationForm(values, slots, errors))
 	}
-	fmt.Println(reservation)
+	kit.Logger().Info("reservation created", "reservation", reservation.ID, "slot", values.TimeSlotID)
 
 	// Get updated slots list after reservation
 	updatedSlots, _ := GetAvailableTimeSlots()
 
 	values.SuccessMessage = "Reservation confirmed!"
-	return kit.Render(CreateReservationForm(values, updatedSlots))
+	return kit.Render(CreateReservationForm(values, updatedSlots, v.Errors{}))
 }
 
 func HandleUserReservations(kit *kit.Kit) error {
@@ -164,10 +131,11 @@ func HandleUserReservations(kit *kit.Kit) error {
 
 	timeSlots, _ := GetAvailableTimeSlots()
 
-	return kit.Render(UserReservations(ReservationPageData{
+	data := ReservationPageData{
 		Reservations: reservations,
 		TimeSlots:    timeSlots,
-	}))
+	}
+	return kit.Respond(http.StatusOK, data, UserReservations(data))
 }
 
 func HandleCancelReservation(kit *kit.Kit) error {
@@ -175,11 +143,9 @@ func HandleCancelReservation(kit *kit.Kit) error {
 	if userID == 0 {
 		return kit.Redirect(303, "/login")
 	}
-	reservationIDStr := chi.URLParam(kit.Request, "id")
-	// reservationIDStr := kit.Request.URL.Query().Get("id")
-	reservationID, err := strconv.ParseUint(reservationIDStr, 10, 32)
+	reservationID, err := kit.URLParamID("id")
 	if err != nil {
-		return kit.Text(400, "Invalid reservation ID")
+		return err
 	}
 
 	// Verify that this reservation belongs to the user
@@ -187,43 +153,38 @@ func HandleCancelReservation(kit *kit.Kit) error {
 
 	var userOwnsReservation bool
 	for _, r := range reservations {
-		if r.ID == uint(reservationID) {
+		if r.ID == reservationID {
 			userOwnsReservation = true
 			break
 		}
 	}
 
 	if !userOwnsReservation {
-		return kit.Text(403, "You don't have permission to cancel this reservation")
+		return kit.Error(http.StatusForbidden, "You don't have permission to cancel this reservation", nil)
 	}
 
-	err = CancelReservation(uint(reservationID))
+	err = CancelReservation(reservationID)
 	if err != nil {
-		return kit.Text(500, "Failed to cancel reservation")
+		return fmt.Errorf("failed to cancel reservation: %w", err)
 	}
 
 	// Get updated reservations
 	updatedReservations, _ := GetUserReservations(userID)
 	timeSlots, _ := GetAvailableTimeSlots()
 
-	return kit.Render(UserReservations(ReservationPageData{
+	data := ReservationPageData{
 		Reservations: updatedReservations,
 		TimeSlots:    timeSlots,
-	}))
+	}
+	return kit.RespondFragment(http.StatusOK, data, UserReservations(data), UserReservationsContent(data))
 }
 
-// Helper function to get user ID from kit context
+// GetUserID returns the ID of the authenticated user, or 0 if there is
+// none.
 func GetUserID(kit *kit.Kit) uint {
-	session := kit.GetSession("user")
-	userIDInterface := session.Values["userID"]
-	if userIDInterface == nil {
+	auth, ok := kit.Auth().(auth.Auth)
+	if !ok || !auth.Check() {
 		return 0
 	}
-
-	userID, ok := userIDInterface.(uint)
-	if !ok {
-		return 0
-	}
-
-	return userID
+	return auth.UserID
 }
@@ -0,0 +1,46 @@
+package reservation
+
+import (
+	"embed"
+	"gothstack/app/plugin"
+	"gothstack/plugins/auth"
+	"io/fs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Plugin is the reservation plugin: time slots users can book.
+type Plugin struct {
+	plugin.Base
+}
+
+func (Plugin) Name() string { return "reservation" }
+
+func (Plugin) Requires() []string { return []string{"auth"} }
+
+func (Plugin) Init(app plugin.App) error {
+	auth.RegisterUserDataHook(auth.UserDataHook{
+		Name:   "reservations",
+		Export: ExportUserData,
+		Erase:  EraseUserData,
+	})
+	return nil
+}
+
+func (Plugin) Routes(router chi.Router, app plugin.App) {
+	InitRoutes(router, app.Auth)
+}
+
+func (Plugin) Migrations() fs.FS {
+	sub, _ := fs.Sub(migrations, "migrations")
+	return sub
+}
+
+func (Plugin) Nav() []plugin.NavItem {
+	return []plugin.NavItem{
+		{Label: "Add TimeSlot", URL: "/admin/timeslots/create", Section: plugin.NavAdmin, Permission: auth.PermissionManageTimeSlots},
+	}
+}
@@ -1,7 +1,9 @@
 package reservation
 
 import (
-	"github.com/anthdm/superkit/kit"
+	"gothstack/kit"
+	"gothstack/plugins/auth"
+
 	"github.com/go-chi/chi/v5"
 )
 
@@ -11,19 +13,19 @@ func InitRoutes(router chi.Router, authConfig kit.AuthenticationConfig) {
 	router.Get("/reservations", kit.Handler(HandleListTimeSlots))
 
 	// Protected routes - require authentication
-	router.Group(func(auth chi.Router) {
+	router.Group(func(protected chi.Router) {
 		// Apply authentication middleware
-		auth.Use(kit.WithAuthentication(authConfig, true))
+		protected.Use(kit.WithAuthentication(authConfig, true))
 
 		// User reservation routes
-		auth.Get("/reservations/create", kit.Handler(HandleReservationForm))
-		auth.Post("/reservations/create", kit.Handler(HandleCreateReservation))
-		auth.Get("/reservations/my", kit.Handler(HandleUserReservations))
-		auth.Post("/reservations/cancel/{id}", kit.Handler(HandleCancelReservation))
+		protected.Get("/reservations/create", kit.Handler(HandleReservationForm))
+		protected.Post("/reservations/create", kit.Handler(HandleCreateReservation))
+		protected.Get("/reservations/my", kit.Handler(HandleUserReservations))
+		protected.Post("/reservations/cancel/{id}", kit.Handler(HandleCancelReservation))
 
-		// Admin routes - could add another middleware to check admin status
-		auth.Group(func(admin chi.Router) {
-			// Add admin middleware here if needed
+		// Admin routes
+		protected.Group(func(admin chi.Router) {
+			admin.Use(kit.WithPermission(auth.PermissionManageTimeSlots))
 			admin.Get("/admin/timeslots/create", kit.Handler(HandleCreateTimeSlotForm))
 			admin.Post("/admin/timeslots/create", kit.Handler(HandleCreateTimeSlot))
 		})
@@ -0,0 +1,47 @@
+package reservation
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type reservationExport struct {
+	TimeSlot  string    `json:"time_slot"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Status    string    `json:"status"`
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportUserData returns the reservations of the user for the personal
+// data export of the auth plugin.
+func ExportUserData(tx *gorm.DB, userID uint) (any, error) {
+	var reservations []Reservation
+	err := tx.Preload("TimeSlot").
+		Where("user_id = ?", userID).
+		Order("created_at").
+		Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	data := []reservationExport{}
+	for _, reservation := range reservations {
+		data = append(data, reservationExport{
+			TimeSlot:  reservation.TimeSlot.Title,
+			StartTime: reservation.TimeSlot.StartTime,
+			EndTime:   reservation.TimeSlot.EndTime,
+			Status:    reservation.Status,
+			Notes:     reservation.Notes,
+			CreatedAt: reservation.CreatedAt,
+		})
+	}
+	return data, nil
+}
+
+// EraseUserData removes the notes the user left on their reservations.
+// The reservations are kept so slot capacity stays correct.
+func EraseUserData(tx *gorm.DB, userID uint) error {
+	return tx.Unscoped().Model(&Reservation{}).Where("user_id = ?", userID).Update("notes", "").Error
+}
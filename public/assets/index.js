@@ -1,4 +1,16 @@
 (() => {
   // app/assets/index.js
   console.log("if you like superkit consider given it a star on GitHub.");
+  document.addEventListener("htmx:configRequest", (event) => {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    if (meta) {
+      event.detail.headers["X-CSRF-Token"] = meta.content;
+    }
+  });
+  document.addEventListener("htmx:beforeSwap", (event) => {
+    if (event.detail.xhr.status >= 400) {
+      event.detail.shouldSwap = true;
+      event.detail.target = document.body;
+    }
+  });
 })();